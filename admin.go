@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// authorizeAdmin Admins authenticate with the ApiKey scheme polka uses, there are no admin users
+func (cfg *apiConfig) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+
+	if cfg.adminApiKey == "" {
+		respondWithError(w, http.StatusNotFound, "Admin endpoints are disabled")
+		return false
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "ApiKey ")
+	if !found || !auth.ConstantTimeEqual(token, cfg.adminApiKey) {
+		log.Printf("Invalid authorization header for admin endpoint %s", r.URL.Path)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+
+	return true
+}
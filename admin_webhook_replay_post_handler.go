@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+func (cfg *apiConfig) adminWebhookReplayPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+
+	event, err := cfg.DB.ReplayWebhookEvent(r.PathValue("eventId"), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.InboxEventNotExists):
+			respondWithError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, database.ErrEventNotDead):
+			respondWithError(w, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Could not replay webhook event: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not replay webhook event")
+		}
+		return
+	}
+
+	log.Printf("Replaying webhook event %s", event.Id)
+	cfg.wakeWebhookWorker()
+	respondWithJSON(w, http.StatusAccepted, event)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) adminWebhooksGetHandler(w http.ResponseWriter, r *http.Request) {
+
+	if !cfg.authorizeAdmin(w, r) {
+		return
+	}
+
+	status := database.InboxStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", database.InboxPending, database.InboxProcessed, database.InboxDead:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status should be pending, processed or dead")
+		return
+	}
+
+	events, err := cfg.DB.WebhookEvents(status)
+	if err != nil {
+		log.Printf("Could not list webhook events: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not list webhook events")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultBookmarksPage = 20
+	maxBookmarksPage     = 100
+)
+
+// bookmarksPage NextCursor is the after parameter of the next page, it is left out on the last one
+type bookmarksPage struct {
+	Bookmarks  []database.BookmarkedChirp `json:"bookmarks"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var after *database.BookmarkCursor
+	if afterParam := r.URL.Query().Get("after"); afterParam != "" {
+		cursor, err := database.ParseBookmarkCursor(afterParam)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Provided cursor is not valid")
+			return
+		}
+		after = &cursor
+	}
+	limit := defaultBookmarksPage
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxBookmarksPage {
+			respondWithError(w, http.StatusBadRequest, "Provided limit is not valid")
+			return
+		}
+	}
+
+	bookmarks, err := cfg.DB.Bookmarks(userId, after, limit)
+	if err != nil {
+		log.Printf("Could not list bookmarks of user with id %d: %q", userId, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve bookmarks")
+		return
+	}
+
+	page := bookmarksPage{Bookmarks: bookmarks}
+	if len(bookmarks) == limit {
+		last := bookmarks[len(bookmarks)-1]
+		page.NextCursor = database.BookmarkCursor{CreatedAt: last.BookmarkedAt, ChirpId: last.Chirp.Id}.String()
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
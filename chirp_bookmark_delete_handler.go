@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// deleteChirpActionHandler Bookmarks are the only thing that can be removed from a chirp this way
+func (cfg *apiConfig) deleteChirpActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("action") != "bookmark" {
+		http.NotFound(w, r)
+		return
+	}
+	cfg.deleteChirpBookmarkHandler(w, r)
+}
+
+func (cfg *apiConfig) deleteChirpBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		log.Printf("Provided chirp id is not valid: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	err = cfg.DB.UnbookmarkChirp(userId, chirpId)
+	if err != nil {
+		log.Printf("Could not remove bookmark of chirp: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not remove bookmark of chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, "")
+}
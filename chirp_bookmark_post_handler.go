@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) postChirpBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		log.Printf("Provided chirp id is not valid: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	err = cfg.DB.BookmarkChirp(userId, chirpId, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ChirpNotExists) {
+			respondWithError(w, http.StatusNotFound, "Chirp does not exist")
+			return
+		}
+		log.Printf("Could not bookmark chirp: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not bookmark chirp")
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestBookmarksAndPins(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	users := []string{
+		`{"email": "alice@chirpy.com", "password": "tangerine-lantern-42"}`,
+		`{"email": "bob@chirpy.com", "password": "crimson-harbor-97"}`,
+	}
+	tokens := make([]string, len(users))
+	for i, user := range users {
+		createW := httptest.NewRecorder()
+		cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+		loginW := httptest.NewRecorder()
+		cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+	}
+
+	for i, token := range []string{tokens[0], tokens[0], tokens[0], tokens[1]} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(fmt.Sprintf(`{"body": "Chirp number %d"}`, i+1)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.postChirpHandler(w, req)
+	}
+
+	bookmark := func(method, token, chirpId, action string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/api/chirps/"+chirpId+"/"+action, nil)
+		req.SetPathValue("chirpId", chirpId)
+		req.SetPathValue("action", action)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if method == "POST" {
+			cfg.postChirpBookmarkHandler(w, req)
+		} else {
+			cfg.deleteChirpActionHandler(w, req)
+		}
+		return w.Code
+	}
+	pin := func(token, body string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PATCH", "/api/users/me", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.patchUsersMeHandler(w, req)
+		return w.Code
+	}
+	listAuthor := func(query string) []int {
+		w := httptest.NewRecorder()
+		cfg.getChirpHandler(w, httptest.NewRequest("GET", "/api/chirps?"+query, nil))
+
+		chirps := []database.Chirp{}
+		_ = json.NewDecoder(w.Body).Decode(&chirps)
+		ids := make([]int, 0, len(chirps))
+		for _, chirp := range chirps {
+			ids = append(ids, chirp.Id)
+		}
+		return ids
+	}
+	listBookmarks := func(query string) bookmarksPage {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/bookmarks?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+		cfg.getBookmarksHandler(w, req)
+
+		page := bookmarksPage{}
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		return page
+	}
+	bookmarkedIds := func(page bookmarksPage) []int {
+		ids := make([]int, 0, len(page.Bookmarks))
+		for _, bookmark := range page.Bookmarks {
+			ids = append(ids, bookmark.Chirp.Id)
+		}
+		return ids
+	}
+
+	bookmarkCases := []struct {
+		method   string
+		chirpId  string
+		action   string
+		wantCode int
+	}{
+		{method: "POST", chirpId: "1", action: "bookmark", wantCode: 204},
+		{method: "POST", chirpId: "2", action: "bookmark", wantCode: 204},
+		{method: "POST", chirpId: "3", action: "bookmark", wantCode: 204},
+		{method: "POST", chirpId: "1", action: "bookmark", wantCode: 204},
+		{method: "POST", chirpId: "99", action: "bookmark", wantCode: 404},
+		{method: "POST", chirpId: "abc", action: "bookmark", wantCode: 400},
+		{method: "DELETE", chirpId: "3", action: "likes", wantCode: 404},
+	}
+
+	for i, c := range bookmarkCases {
+		t.Run(fmt.Sprintf("Bookmarks And Pins Bookmark Test Case %d", i), func(t *testing.T) {
+			if got := bookmark(c.method, tokens[1], c.chirpId, c.action); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	pinCases := []struct {
+		body     string
+		wantCode int
+	}{
+		// Only the author can pin a chirp
+		{body: `{"pinned_chirp_id": 4}`, wantCode: 400},
+		{body: `{"pinned_chirp_id": 99}`, wantCode: 400},
+		{body: `{"pinned_chirp_id": -1}`, wantCode: 400},
+		{body: `{"pinned_chirp_id": 2}`, wantCode: 200},
+	}
+
+	for i, c := range pinCases {
+		t.Run(fmt.Sprintf("Bookmarks And Pins Pin Test Case %d", i), func(t *testing.T) {
+			if got := pin(tokens[0], c.body); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Bookmarks And Pins Profile Test", func(t *testing.T) {
+		if got, want := listAuthor("author_id=1"), []int{2, 1, 3}; !slices.Equal(got, want) {
+			t.Errorf("Test failed (asc): got %v, want %v", got, want)
+		}
+		if got, want := listAuthor("author_id=1&sort=desc"), []int{2, 3, 1}; !slices.Equal(got, want) {
+			t.Errorf("Test failed (desc): got %v, want %v", got, want)
+		}
+		// Only profile listings surface the pin
+		if got, want := listAuthor(""), []int{1, 2, 3, 4}; !slices.Equal(got, want) {
+			t.Errorf("Test failed (all): got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Bookmarks And Pins Pagination Test", func(t *testing.T) {
+		first := listBookmarks("limit=2")
+		if got, want := bookmarkedIds(first), []int{3, 2}; !slices.Equal(got, want) || first.NextCursor == "" {
+			t.Fatalf("Test failed (first page): got %v with cursor %q, want %v", got, first.NextCursor, want)
+		}
+		second := listBookmarks("limit=2&after=" + first.NextCursor)
+		if got, want := bookmarkedIds(second), []int{1}; !slices.Equal(got, want) || second.NextCursor != "" {
+			t.Errorf("Test failed (second page): got %v with cursor %q, want %v", got, second.NextCursor, want)
+		}
+	})
+
+	t.Run("Bookmarks And Pins Hidden Pin Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "Followers only", "visibility": "followers"}`))
+		req.Header.Set("Authorization", "Bearer "+tokens[0])
+		cfg.postChirpHandler(w, req)
+		if got := pin(tokens[0], `{"pinned_chirp_id": 5}`); got != 200 {
+			t.Fatalf("Test failed (pin): got %d, want %d", got, 200)
+		}
+		defer pin(tokens[0], `{"pinned_chirp_id": 2}`)
+
+		profile := func(token string) PublicProfile {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/users/1", nil)
+			req.SetPathValue("userId", "1")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			cfg.getUserHandler(w, req)
+
+			profile := PublicProfile{}
+			_ = json.NewDecoder(w.Body).Decode(&profile)
+			return profile
+		}
+		if got := profile("").PinnedChirpId; got != 0 {
+			t.Errorf("Test failed (anonymous): got %d, want %d", got, 0)
+		}
+		if got := profile(tokens[1]).PinnedChirpId; got != 0 {
+			t.Errorf("Test failed (not a follower): got %d, want %d", got, 0)
+		}
+		if got := profile(tokens[0]).PinnedChirpId; got != 5 {
+			t.Errorf("Test failed (author): got %d, want %d", got, 5)
+		}
+	})
+
+	t.Run("Bookmarks And Pins Delete Test", func(t *testing.T) {
+		if err := db.DeleteChirpById(2, 1); err != nil {
+			t.Fatalf("Could not delete chirp: %q", err)
+		}
+
+		alice, _ := db.UserById(1)
+		if alice.PinnedChirpId != 0 {
+			t.Errorf("Test failed (pin): got %d, want %d", alice.PinnedChirpId, 0)
+		}
+		data, _ := db.UserData(2)
+		if len(data.Bookmarks) != 2 {
+			t.Errorf("Test failed (bookmarks): got %d, want %d", len(data.Bookmarks), 2)
+		}
+
+		if got := bookmark("DELETE", tokens[1], "3", "bookmark"); got != 204 {
+			t.Errorf("Test failed (unbookmark): got %d, want %d", got, 204)
+		}
+		if got, want := bookmarkedIds(listBookmarks("")), []int{1}; !slices.Equal(got, want) {
+			t.Errorf("Test failed (after delete): got %v, want %v", got, want)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// urlWeight Every link counts the same no matter how long it is, like a shortened url would
+	urlWeight = 23
+	// maxURLLength Longer links count as the text they are
+	maxURLLength = 2048
+	// maxChirpRequestBytes Bounds what is read of chirp, draft and message requests
+	maxChirpRequestBytes = 64 << 10
+	// maxChirpBytes A grapheme cluster can carry any number of combining marks, so the length limit alone does not bound the size
+	maxChirpBytes = 8 << 10
+)
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// mentionPattern An @handle that is not part of a word or an email address
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@.])(@[A-Za-z0-9_]{3,30})\b`)
+
+var (
+	ErrInvalidEncoding  = errors.New("chirp is not valid UTF-8")
+	ErrControlCharacter = errors.New("chirp contains control characters")
+	ErrChirpTooLarge    = errors.New("chirp is too large")
+)
+
+// chirpLengthError Returned when a chirp is over the limit of the author's plan
+type chirpLengthError struct {
+	Length int
+	Limit  int
+}
+
+func (e chirpLengthError) Error() string {
+	return fmt.Sprintf("chirp length (%d) exceeds limit (%d)", e.Length, e.Limit)
+}
+
+// decodeChirp The json decoder silently swaps invalid UTF-8 for U+FFFD, so the raw bytes are checked first
+func decodeChirp(w http.ResponseWriter, r *http.Request, v any) error {
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChirpRequestBytes))
+	if err != nil {
+		maxBytesErr := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesErr) {
+			return ErrChirpTooLarge
+		}
+		return err
+	}
+
+	if !utf8.Valid(data) {
+		return ErrInvalidEncoding
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// normalizeChirp Rejects bodies that can not be displayed safely and returns the NFC form that gets stored
+func normalizeChirp(body string) (string, error) {
+
+	if !utf8.ValidString(body) {
+		return "", ErrInvalidEncoding
+	}
+
+	normalized := norm.NFC.String(body)
+	for _, r := range normalized {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return "", ErrControlCharacter
+		}
+	}
+
+	return normalized, nil
+}
+
+// findURLs Byte offsets of every url in body, without the punctuation that usually ends a sentence
+func findURLs(body string) [][]int {
+	locs := urlPattern.FindAllStringIndex(body, -1)
+	for _, loc := range locs {
+		trimmed := strings.TrimRight(body[loc[0]:loc[1]], `.,;:!?'")]`)
+		loc[1] = loc[0] + len(trimmed)
+	}
+	return locs
+}
+
+// chirpEntities Extracts the links and mentions of a validated chirp body, offsets are in code points.
+// Mentions are matched to users when the chirp is stored.
+func chirpEntities(body string) []database.Entity {
+	var entities []database.Entity
+	urls := findURLs(body)
+	for _, loc := range urls {
+		start := utf8.RuneCountInString(body[:loc[0]])
+		entities = append(entities, database.Entity{
+			Type:  database.EntityURL,
+			Start: start,
+			End:   start + utf8.RuneCountInString(body[loc[0]:loc[1]]),
+			URL:   body[loc[0]:loc[1]],
+		})
+	}
+
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		// The @ and the handle, without the character before it
+		at, end := match[2], match[3]
+		if slices.ContainsFunc(urls, func(loc []int) bool { return at < loc[1] && end > loc[0] }) {
+			continue
+		}
+		start := utf8.RuneCountInString(body[:at])
+		entities = append(entities, database.Entity{
+			Type:   database.EntityMention,
+			Start:  start,
+			End:    start + utf8.RuneCountInString(body[at:end]),
+			Handle: body[at+1 : end],
+		})
+	}
+
+	slices.SortFunc(entities, func(a, b database.Entity) int { return cmp.Compare(a.Start, b.Start) })
+	return entities
+}
+
+// chirpLength Counts user perceived characters (grapheme clusters), with every url weighing urlWeight
+func chirpLength(body string) int {
+
+	length, last := 0, 0
+	for _, loc := range findURLs(body) {
+		if loc[1]-loc[0] > maxURLLength {
+			continue
+		}
+		length += uniseg.GraphemeClusterCount(body[last:loc[0]]) + urlWeight
+		last = loc[1]
+	}
+
+	return length + uniseg.GraphemeClusterCount(body[last:])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// maxScheduleAhead Chirps can not be scheduled further away than this
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+func validatePublishAt(publishAt, now time.Time) error {
+	if !publishAt.After(now) {
+		return errors.New("publish_at must be in the future")
+	}
+	if publishAt.After(now.Add(maxScheduleAhead)) {
+		return errors.New("publish_at can be at most one year ahead")
+	}
+	return nil
+}
+
+func (cfg *apiConfig) wakeScheduler() {
+	if cfg.schedulerWake == nil {
+		return
+	}
+	select {
+	case cfg.schedulerWake <- struct{}{}:
+	default:
+	}
+}
+
+// runChirpScheduler Sleeps until the next scheduled chirp is due, but never longer than interval
+// so chirps scheduled by another instance sharing the database are picked up too.
+func (cfg *apiConfig) runChirpScheduler(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting chirp scheduler, checking for due chirps at least every %v", interval)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping chirp scheduler: %q", ctx.Err())
+			return
+		case <-cfg.schedulerWake:
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		_, next := cfg.publishScheduledChirps(now)
+
+		wait := interval
+		if !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// publishScheduledChirps Publishes the chirps that are due and returns how many, along with when the next one is due
+func (cfg *apiConfig) publishScheduledChirps(now time.Time) (int, time.Time) {
+
+	published, next, err := cfg.DB.PublishDueChirps(now.UTC())
+	if err != nil {
+		log.Printf("Chirp scheduler could not publish due chirps: %q", err)
+		return 0, time.Time{}
+	}
+
+	for _, chirp := range published {
+		log.Printf("Published scheduled chirp with id %d", chirp.Id)
+		cfg.emitEvent(eventChirpCreated, chirp.AuthorId, chirp)
+	}
+
+	return len(published), next
+}
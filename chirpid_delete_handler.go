@@ -47,5 +47,11 @@ func (cfg *apiConfig) deleteChirpIdHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	cfg.emitEvent(eventChirpDeleted, userId, ChirpDeletedData{Id: chirpId, AuthorId: userId})
 	respondWithJSON(w, http.StatusNoContent, "")
 }
+
+type ChirpDeletedData struct {
+	Id       int `json:"id"`
+	AuthorId int `json:"author_id"`
+}
@@ -17,7 +17,15 @@ func (cfg *apiConfig) chirpIdGetHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	chirp, err := cfg.DB.ChirpById(id)
+	viewerId, err := cfg.viewerId(r)
+	if err != nil {
+		log.Printf("Could not identify chirp viewer: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Hidden chirps are reported as missing, so their existence is not leaked
+	chirp, err := cfg.DB.ChirpById(id, viewerId)
 
 	// TODO: Distinguish between 404 and 500
 	if err != nil {
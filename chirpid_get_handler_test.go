@@ -55,7 +55,7 @@ func TestGetChirpIdHandler(t *testing.T) {
 		{
 			code: 200,
 			id:   "1",
-			want: `{"body":"A good chirp","id":1,"author_id":1}`,
+			want: `{"body":"A good chirp","id":1,"author_id":1,"visibility":"public"}`,
 		},
 		{
 			code: 400,
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) putChirpIdHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		log.Printf("Provided chirp id to edit is not valid: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	author, err := cfg.DB.UserById(userId)
+	if err != nil {
+		log.Printf("Could not find chirp author with id %d: %q", userId, err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	allowed := cfg.entitlementsFor(author)
+	if !allowed.CanEdit {
+		respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red")
+		return
+	}
+
+	type chirpParams struct {
+		Body string `json:"body"`
+	}
+	params := chirpParams{}
+	err = decodeChirp(w, r, &params)
+	if errors.Is(err, ErrInvalidEncoding) || errors.Is(err, ErrChirpTooLarge) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error decoding chirp: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode chirp")
+		return
+	}
+
+	sanitized, err := validateChirp(params.Body, allowed.MaxChirpLength)
+	if err != nil {
+		respondWithChirpError(w, err)
+		return
+	}
+
+	chirp, err := cfg.DB.UpdateChirpBody(chirpId, userId, sanitized, chirpEntities(sanitized), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.IncorrectAuthorId) || errors.Is(err, database.ChirpNotExists) {
+			log.Printf("Received chirp id is incorrect: %q", err)
+			respondWithError(w, http.StatusForbidden, "You are not authorized to do that")
+			return
+		}
+		log.Printf("Error received trying to edit chirp: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal error")
+		return
+	}
+
+	cfg.wakePreviewFetcher()
+	cfg.emitEvent(eventChirpUpdated, userId, chirp)
+	respondWithJSON(w, http.StatusOK, chirp)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestChirpEntitlements(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+		plans: map[plan]entitlements{
+			planFree: {MaxChirpLength: 140, ChirpsPerHour: 2},
+			planRed:  {MaxChirpLength: 280, CanEdit: true, CanSchedule: true, ChirpsPerHour: 5},
+		},
+	}
+
+	users := []string{
+		`{"email": "free@chirpy.com", "password": "tangerine-lantern-42"}`,
+		`{"email": "red@chirpy.com", "password": "crimson-harbor-97"}`,
+	}
+	tokens := make([]string, len(users))
+	for i, user := range users {
+		createW := httptest.NewRecorder()
+		createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+		cfg.postUsersHandler(createW, createReq)
+
+		loginW := httptest.NewRecorder()
+		loginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(loginW, loginReq)
+
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+	}
+
+	_, err = db.UpdateSubscription(2, database.SubscriptionChange{
+		Event:     "user.upgraded",
+		Status:    database.SubscriptionActive,
+		PeriodEnd: time.Now().Add(time.Hour),
+		At:        time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Could not upgrade user to chirpy red: %q", err)
+	}
+
+	long := fmt.Sprintf(`{"body": %q}`, strings.Repeat("a", 200))
+
+	postCases := []struct {
+		token    string
+		body     string
+		wantCode int
+	}{
+		{token: tokens[0], body: long, wantCode: 400},
+		{token: tokens[1], body: long, wantCode: 201},
+		// Chirps that fail to save do not count against the limit
+		{token: tokens[0], body: `{"body": "Missing media", "media_ids": ["missing"]}`, wantCode: 400},
+		{token: tokens[0], body: `{"body": "First free chirp"}`, wantCode: 201},
+		{token: tokens[0], body: `{"body": "Second free chirp"}`, wantCode: 201},
+		// Free plan allows two chirps per hour
+		{token: tokens[0], body: `{"body": "Third free chirp"}`, wantCode: 429},
+		{token: tokens[1], body: `{"body": "Red users can keep chirping"}`, wantCode: 201},
+	}
+
+	for i, c := range postCases {
+		t.Run(fmt.Sprintf("Chirp Entitlements Post Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(c.body))
+			req.Header.Set("Authorization", "Bearer "+c.token)
+
+			cfg.postChirpHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+			if c.wantCode == 429 && w.Header().Get("Retry-After") == "" {
+				t.Errorf("Test failed (retry after): missing header")
+			}
+		})
+	}
+
+	putCases := []struct {
+		token    string
+		chirpId  string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		// Chirp 2 belongs to the free user, editing requires Chirpy Red
+		{token: tokens[0], chirpId: "2", body: `{"body": "Edited"}`, wantCode: 403, wantBody: `{"error":"Editing chirps requires Chirpy Red"}`},
+		// Red users can only edit their own chirps
+		{token: tokens[1], chirpId: "2", body: `{"body": "Edited"}`, wantCode: 403, wantBody: `{"error":"You are not authorized to do that"}`},
+		{token: tokens[1], chirpId: "1", body: fmt.Sprintf(`{"body": %q}`, strings.Repeat("a", 281)), wantCode: 400, wantBody: `{"error":"chirp length exceeds limit","length":281,"limit":280}`},
+		{token: tokens[1], chirpId: "abc", body: `{"body": "Edited"}`, wantCode: 400, wantBody: `{"error":"Invalid chirp id"}`},
+	}
+
+	for i, c := range putCases {
+		t.Run(fmt.Sprintf("Chirp Entitlements Put Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/api/chirps/"+c.chirpId, strings.NewReader(c.body))
+			req.SetPathValue("chirpId", c.chirpId)
+			req.Header.Set("Authorization", "Bearer "+c.token)
+
+			cfg.putChirpIdHandler(w, req)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Chirp Entitlements Edit Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/api/chirps/1", strings.NewReader(`{"body": "Edited by a fornax"}`))
+		req.SetPathValue("chirpId", "1")
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+
+		cfg.putChirpIdHandler(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("Test failed (code): got %d, want %d", w.Code, 200)
+		}
+		chirp := database.Chirp{}
+		_ = json.NewDecoder(w.Body).Decode(&chirp)
+		if chirp.Body != "Edited by a ****" || chirp.EditedAt == nil {
+			t.Errorf("Test failed (edited chirp): got %+v", chirp)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
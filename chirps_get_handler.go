@@ -2,16 +2,42 @@ package main
 
 import (
 	"cmp"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
 	"log"
 	"net/http"
 	"slices"
 	"strconv"
+	"strings"
 )
 
+// viewerId The user reading chirps, zero for anonymous readers. Only a token that was sent and is not valid is an error.
+func (cfg *apiConfig) viewerId(r *http.Request) (int, error) {
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return 0, nil
+	}
+
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		return 0, errors.New("invalid authorization header")
+	}
+
+	return auth.UserIdFromToken(token, cfg.jwtSecret)
+}
+
 func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
 
-	chirps, err := cfg.DB.GetChirps()
+	viewerId, err := cfg.viewerId(r)
+	if err != nil {
+		log.Printf("Could not identify chirps viewer: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	chirps, err := cfg.DB.GetChirps(viewerId)
 
 	if err != nil {
 		log.Printf("Error retrieving chirps from database: %q", err)
@@ -57,5 +83,28 @@ func (cfg *apiConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	if authorIdParam != 0 {
+		filteredChirps = cfg.pinnedFirst(authorIdParam, filteredChirps)
+	}
+
 	respondWithJSON(w, httpStatus, filteredChirps)
 }
+
+// pinnedFirst Moves the chirp the author pinned to the top of their chirps, whatever the sort order
+func (cfg *apiConfig) pinnedFirst(authorId int, chirps []database.Chirp) []database.Chirp {
+
+	author, err := cfg.DB.UserById(authorId)
+	if err != nil || author.PinnedChirpId == 0 {
+		return chirps
+	}
+
+	i := slices.IndexFunc(chirps, func(chirp database.Chirp) bool { return chirp.Id == author.PinnedChirpId })
+	if i <= 0 {
+		return chirps
+	}
+	pinned := chirps[i]
+	copy(chirps[1:i+1], chirps[:i])
+	chirps[0] = pinned
+
+	return chirps
+}
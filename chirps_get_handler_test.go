@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/benjamin-vq/chirpy/internal/database"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestChirpsGetHandler(t *testing.T) {
@@ -86,3 +88,142 @@ func TestChirpsGetHandler(t *testing.T) {
 		log.Fatalf("Could not cleanup database file: %q", err)
 	}
 }
+
+func TestChirpVisibility(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	// author, follower, mentioned and stranger
+	handles := []string{"author", "fan", "pal", "stranger"}
+	tokens := make([]string, len(handles))
+	for i, handle := range handles {
+		user := fmt.Sprintf(`{"email": "%s@chirpy.com", "password": "tangerine-lantern-42"}`, handle)
+		createW := httptest.NewRecorder()
+		cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+		loginW := httptest.NewRecorder()
+		cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+
+		dbUser, _ := db.UserById(i + 1)
+		dbUser.Handle = handle
+		if err := db.UpdateUser(&dbUser); err != nil {
+			t.Fatalf("Could not set handle: %q", err)
+		}
+	}
+	if err := db.FollowUser(2, 1, time.Now()); err != nil {
+		t.Fatalf("Could not follow author: %q", err)
+	}
+
+	postCases := []struct {
+		body     string
+		wantCode int
+	}{
+		{body: `{"body": "Hello world"}`, wantCode: 201},
+		{body: `{"body": "Only for my followers", "visibility": "followers"}`, wantCode: 201},
+		{body: `{"body": "Psst @PAL, mail me at pal@chirpy.com", "visibility": "mentioned"}`, wantCode: 201},
+		{body: `{"body": "Secret", "visibility": "friends"}`, wantCode: 400},
+	}
+
+	for i, c := range postCases {
+		t.Run(fmt.Sprintf("Chirp Visibility Post Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(c.body))
+			req.Header.Set("Authorization", "Bearer "+tokens[0])
+			cfg.postChirpHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Chirp Visibility Mention Entities Test", func(t *testing.T) {
+		chirp, _ := db.ChirpById(3, 1)
+		// The email address is not a mention
+		if len(chirp.Entities) != 1 || chirp.Entities[0].UserId != 3 || chirp.Entities[0].Start != 5 || chirp.Entities[0].End != 9 {
+			t.Errorf("Test failed (mentions): got %+v", chirp.Entities)
+		}
+	})
+
+	cases := []struct {
+		token      string
+		wantChirps int
+		wantCodes  [3]int
+	}{
+		{token: "", wantChirps: 1, wantCodes: [3]int{200, 404, 404}},
+		{token: tokens[0], wantChirps: 3, wantCodes: [3]int{200, 200, 200}},
+		{token: tokens[1], wantChirps: 2, wantCodes: [3]int{200, 200, 404}},
+		{token: tokens[2], wantChirps: 2, wantCodes: [3]int{200, 404, 200}},
+		{token: tokens[3], wantChirps: 1, wantCodes: [3]int{200, 404, 404}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Chirp Visibility Get Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/chirps", nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			cfg.getChirpHandler(w, req)
+
+			chirps := []database.Chirp{}
+			_ = json.NewDecoder(w.Body).Decode(&chirps)
+			if len(chirps) != c.wantChirps {
+				t.Errorf("Test failed (chirps): got %d, want %d", len(chirps), c.wantChirps)
+			}
+
+			for id, wantCode := range c.wantCodes {
+				chirpId := fmt.Sprint(id + 1)
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", "/api/chirps/"+chirpId, nil)
+				req.SetPathValue("chirpId", chirpId)
+				if c.token != "" {
+					req.Header.Set("Authorization", "Bearer "+c.token)
+				}
+				cfg.chirpIdGetHandler(w, req)
+
+				if w.Code != wantCode {
+					t.Errorf("Test failed (chirp %s code): got %d, want %d", chirpId, w.Code, wantCode)
+				}
+			}
+		})
+	}
+
+	t.Run("Chirp Visibility Invalid Token Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps", nil)
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		cfg.getChirpHandler(w, req)
+
+		if w.Code != 401 {
+			t.Errorf("Test failed (code): got %d, want %d", w.Code, 401)
+		}
+	})
+
+	t.Run("Chirp Visibility Profile Stats Test", func(t *testing.T) {
+		stats, _ := db.ProfileStats(1)
+		if stats.Chirps != 1 {
+			t.Errorf("Test failed (public chirps): got %d, want %d", stats.Chirps, 1)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
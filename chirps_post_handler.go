@@ -1,14 +1,18 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
+const maxChirpMedia = 4
+
 func (cfg *apiConfig) postChirpHandler(w http.ResponseWriter, r *http.Request) {
 
 	authHeader := r.Header.Get("Authorization")
@@ -26,46 +30,154 @@ func (cfg *apiConfig) postChirpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	author, ok := cfg.chirpAuthor(w, userId)
+	if !ok {
+		return
+	}
+
 	type chirpParams struct {
-		Body string `json:"body"`
+		Body       string     `json:"body"`
+		MediaIds   []string   `json:"media_ids"`
+		PublishAt  *time.Time `json:"publish_at"`
+		Visibility string     `json:"visibility"`
 	}
 	params := chirpParams{}
 
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&params)
-
+	err = decodeChirp(w, r, &params)
+	if errors.Is(err, ErrInvalidEncoding) || errors.Is(err, ErrChirpTooLarge) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		log.Printf("Error decoding chirp: %q", err)
 		respondWithError(w, http.StatusInternalServerError, "Could not decode chirp")
 		return
 	}
 
-	sanitized, err := validateChirp(params.Body)
+	newChirp, ok := cfg.newChirp(w, author, params.Body, params.MediaIds, params.PublishAt, params.Visibility)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.DB.CreateChirp(newChirp)
+	if err != nil {
+		cfg.chirpLimiter.refund(author.Id)
+	}
+	cfg.respondWithNewChirp(w, chirp, err)
+}
 
+// chirpAuthor Loads the user posting a chirp, responding with an error when they can not post
+func (cfg *apiConfig) chirpAuthor(w http.ResponseWriter, userId int) (database.User, bool) {
+
+	author, err := cfg.DB.UserById(userId)
+	if err != nil {
+		log.Printf("Could not find chirp author with id %d: %q", userId, err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return database.User{}, false
+	}
+	if cfg.requireVerifiedEmail && !author.EmailVerified {
+		log.Printf("User with id %d tried to chirp without a verified email", userId)
+		respondWithError(w, http.StatusForbidden, "Email address is not verified")
+		return database.User{}, false
+	}
+
+	return author, true
+}
+
+// newChirp Runs every check a chirp has to pass before it is stored, with the entitlements of its author.
+// It counts against the rate limit of the author, so it has to be the last step before saving and a failed save
+// has to refund it.
+func (cfg *apiConfig) newChirp(w http.ResponseWriter, author database.User, body string, mediaIds []string, publishAt *time.Time, visibility string) (database.Chirp, bool) {
+
+	allowed := cfg.entitlementsFor(author)
+
+	parsedVisibility, err := database.ParseVisibility(visibility)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
+		return database.Chirp{}, false
 	}
 
-	chirp, err := cfg.DB.CreateChirp(sanitized, userId)
+	sanitized, err := validateChirp(body, allowed.MaxChirpLength)
 	if err != nil {
+		respondWithChirpError(w, err)
+		return database.Chirp{}, false
+	}
+
+	if publishAt != nil {
+		if !allowed.CanSchedule {
+			respondWithError(w, http.StatusForbidden, "Scheduling chirps requires Chirpy Red")
+			return database.Chirp{}, false
+		}
+		if err := validatePublishAt(*publishAt, time.Now()); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return database.Chirp{}, false
+		}
+	}
+
+	if len(mediaIds) > maxChirpMedia {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("a chirp can have at most %d media", maxChirpMedia))
+		return database.Chirp{}, false
+	}
+
+	if !cfg.checkChirpRate(w, author.Id, allowed) {
+		return database.Chirp{}, false
+	}
+
+	return database.Chirp{
+		Body:       sanitized,
+		AuthorId:   author.Id,
+		Visibility: parsedVisibility,
+		MediaIds:   mediaIds,
+		Entities:   chirpEntities(sanitized),
+		PublishAt:  publishAt,
+	}, true
+}
+
+// respondWithNewChirp Finishes creating a chirp once it was saved, err is the one returned while saving it
+func (cfg *apiConfig) respondWithNewChirp(w http.ResponseWriter, chirp database.Chirp, err error) {
+
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidMedia) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, database.UserNotExists) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
 		log.Printf("Could not save chirp to database: %q", err)
 		respondWithError(w, 500, "Could not post chirp")
 		return
 	}
 
+	cfg.wakePreviewFetcher()
+	if chirp.Scheduled() {
+		cfg.wakeScheduler()
+	} else {
+		cfg.emitEvent(eventChirpCreated, chirp.AuthorId, chirp)
+	}
 	respondWithJSON(w, http.StatusCreated, chirp)
 }
 
-func validateChirp(body string) (string, error) {
-	const limit = 140
+func validateChirp(body string, limit int) (string, error) {
+
+	normalized, err := normalizeChirp(body)
+	if err != nil {
+		log.Printf("Decoded chirp body is not valid: %q", err)
+		return "", err
+	}
+
+	if len(normalized) > maxChirpBytes {
+		log.Printf("Decoded chirp body (%d bytes) is greater than the limit (%d bytes)", len(normalized), maxChirpBytes)
+		return "", ErrChirpTooLarge
+	}
 
-	if chirpLen := len(body); chirpLen > limit {
+	if chirpLen := chirpLength(normalized); chirpLen > limit {
 		log.Printf("Decoded chirp body (%d) is greater than the limit (%d)", chirpLen, limit)
-		return "", errors.New("chirp length exceeds limit")
+		return "", chirpLengthError{Length: chirpLen, Limit: limit}
 	}
 
-	sanitized := replaceBadWords(body)
+	sanitized := replaceBadWords(normalized)
 	log.Print("Chirp validated successfully")
 
 	return sanitized, nil
@@ -52,23 +52,49 @@ func TestPostChirpHandler(t *testing.T) {
 		{
 			code: 201,
 			body: `{"body": "A good chirp"}`,
-			want: `{"body":"A good chirp","id":1,"author_id":1}`,
+			want: `{"body":"A good chirp","id":1,"author_id":1,"visibility":"public"}`,
 		},
 		{
 			code: 201,
 			body: `{"body": "A decent chirp, chirped by fornax (not Fornax)"}`,
-			want: `{"body":"A decent chirp, chirped by **** (not ****)","id":2,"author_id":1}`,
+			want: `{"body":"A decent chirp, chirped by **** (not ****)","id":2,"author_id":1,"visibility":"public"}`,
 		},
 		{
 			code: 400,
 			body: `{"body": "A really really long, omnipotent chirp, one may call it the best chirp. Capable of surpassing the longest of limits, beyond human imagination."}`,
-			want: `{"error":"chirp length exceeds limit"}`,
+			want: `{"error":"chirp length exceeds limit","length":142,"limit":140}`,
+		},
+		{
+			code: 201,
+			body: fmt.Sprintf(`{"body": %q}`, strings.Repeat("👍🏽", 140)),
+			want: `{"body":"` + strings.Repeat("👍🏽", 140) + `","id":3,"author_id":1,"visibility":"public"}`,
+		},
+		{
+			code: 400,
+			body: `{"body": "Bell \u0007 chirp"}`,
+			want: `{"error":"chirp contains control characters"}`,
+		},
+		{
+			code: 400,
+			body: "{\"body\": \"Broken \xff chirp\"}",
+			want: `{"error":"chirp is not valid UTF-8"}`,
 		},
 		{
 			code: 500,
 			body: `invalid json`,
 			want: `{"error":"Could not decode chirp"}`,
 		},
+		{
+			// A single grapheme cluster
+			code: 400,
+			body: fmt.Sprintf(`{"body": %q}`, "a"+strings.Repeat("\u0301", maxChirpBytes)),
+			want: `{"error":"chirp is too large"}`,
+		},
+		{
+			code: 400,
+			body: fmt.Sprintf(`{"body": "A good chirp", "padding": %q}`, strings.Repeat("a", maxChirpRequestBytes)),
+			want: `{"error":"chirp is too large"}`,
+		},
 	}
 
 	for i, c := range cases {
@@ -138,3 +164,42 @@ func TestReplaceBadWords(t *testing.T) {
 		})
 	}
 }
+
+func TestChirpLength(t *testing.T) {
+
+	cases := []struct {
+		body string
+		want int
+	}{
+		{body: "", want: 0},
+		{body: "Hello", want: 5},
+		{body: "héllo", want: 5},
+		// e followed by a combining acute accent
+		{body: "he\u0301llo", want: 5},
+		{body: "こんにちは", want: 5},
+		{body: "👍🏽👍🏽", want: 2},
+		{body: "🇪🇸 flag", want: 6},
+		{body: "https://example.com/a/very/long/path/that/keeps/going?query=1", want: urlWeight},
+		{body: "Read this: http://chirpy.com and this https://boot.dev", want: 11 + urlWeight + 10 + urlWeight},
+		{body: "https://chirpy.com/" + strings.Repeat("a", maxURLLength), want: 19 + maxURLLength},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Chirp Length Test Case %d", i), func(t *testing.T) {
+			normalized, err := normalizeChirp(c.body)
+			if err != nil {
+				t.Fatalf("Test failed (normalize): %q", err)
+			}
+			if got := chirpLength(normalized); got != c.want {
+				t.Errorf("Test failed: got %d, want %d", got, c.want)
+			}
+		})
+	}
+
+	t.Run("Chirp Normalization Test", func(t *testing.T) {
+		normalized, _ := normalizeChirp("he\u0301llo")
+		if normalized != "h\u00e9llo" {
+			t.Errorf("Test failed: got %q, want %q", normalized, "h\u00e9llo")
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// deleteScheduledChirpHandler Cancels a chirp before it is published, no chirp.deleted event is sent for it
+func (cfg *apiConfig) deleteScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		log.Printf("Provided scheduled chirp id is not valid: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	err = cfg.DB.CancelScheduledChirp(chirpId, userId)
+	if err != nil {
+		respondWithScheduledError(w, err)
+		return
+	}
+
+	log.Printf("Cancelled scheduled chirp with id %d", chirpId)
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestScheduledChirpsHandlers(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	users := []string{
+		`{"email": "free@chirpy.com", "password": "tangerine-lantern-42"}`,
+		`{"email": "social@chirpy.com", "password": "crimson-harbor-97"}`,
+	}
+	tokens := make([]string, len(users))
+	for i, user := range users {
+		createW := httptest.NewRecorder()
+		cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+		loginW := httptest.NewRecorder()
+		cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+	}
+
+	_, err = db.UpdateSubscription(2, database.SubscriptionChange{
+		Event:     "user.upgraded",
+		Status:    database.SubscriptionActive,
+		PeriodEnd: time.Now().Add(24 * time.Hour),
+		At:        time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Could not upgrade user to chirpy red: %q", err)
+	}
+
+	inOneHour := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	inTwoHours := inOneHour.Add(time.Hour)
+	scheduledBody := func(body string, at time.Time) string {
+		return fmt.Sprintf(`{"body": %q, "publish_at": %q}`, body, at.Format(time.RFC3339))
+	}
+
+	postCases := []struct {
+		token    string
+		body     string
+		wantCode int
+	}{
+		{token: tokens[0], body: scheduledBody("Free users can not schedule", inOneHour), wantCode: 403},
+		{token: tokens[1], body: scheduledBody("Back to the past", time.Now().Add(-time.Minute)), wantCode: 400},
+		{token: tokens[1], body: scheduledBody("Too far away", time.Now().Add(2*maxScheduleAhead)), wantCode: 400},
+		{token: tokens[1], body: scheduledBody("Launch day!", inOneHour), wantCode: 201},
+		{token: tokens[1], body: scheduledBody("Never mind", inOneHour), wantCode: 201},
+	}
+
+	for i, c := range postCases {
+		t.Run(fmt.Sprintf("Scheduled Chirps Post Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(c.body))
+			req.Header.Set("Authorization", "Bearer "+c.token)
+
+			cfg.postChirpHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	listScheduled := func(token string) []database.Chirp {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps/scheduled", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.getScheduledChirpsHandler(w, req)
+
+		chirps := []database.Chirp{}
+		if err := json.NewDecoder(w.Body).Decode(&chirps); err != nil {
+			t.Fatalf("Could not decode scheduled chirps: %q", err)
+		}
+		return chirps
+	}
+	getChirp := func(id string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps/"+id, nil)
+		req.SetPathValue("chirpId", id)
+		cfg.chirpIdGetHandler(w, req)
+		return w.Code
+	}
+
+	t.Run("Scheduled Chirps Hidden Test", func(t *testing.T) {
+		if got := len(listScheduled(tokens[1])); got != 2 {
+			t.Errorf("Test failed (author scheduled): got %d, want %d", got, 2)
+		}
+		if got := len(listScheduled(tokens[0])); got != 0 {
+			t.Errorf("Test failed (other user scheduled): got %d, want %d", got, 0)
+		}
+		if got := getChirp("1"); got != 404 {
+			t.Errorf("Test failed (chirp by id): got %d, want %d", got, 404)
+		}
+
+		w := httptest.NewRecorder()
+		cfg.getChirpHandler(w, httptest.NewRequest("GET", "/api/chirps", nil))
+		if w.Code != 204 {
+			t.Errorf("Test failed (chirps): got %d, want %d", w.Code, 204)
+		}
+	})
+
+	changeCases := []struct {
+		method   string
+		token    string
+		chirpId  string
+		body     string
+		wantCode int
+	}{
+		{method: "PUT", token: tokens[0], chirpId: "1", body: `{"body": "Hijacked"}`, wantCode: 403},
+		{method: "PUT", token: tokens[1], chirpId: "1", body: `{"publish_at": "2001-01-01T00:00:00Z"}`, wantCode: 400},
+		{method: "PUT", token: tokens[1], chirpId: "9", body: `{"body": "Missing"}`, wantCode: 404},
+		{method: "PUT", token: tokens[1], chirpId: "1", body: scheduledBody("Launch day, for real!", inTwoHours), wantCode: 200},
+		{method: "DELETE", token: tokens[0], chirpId: "2", wantCode: 404},
+		{method: "DELETE", token: tokens[1], chirpId: "2", wantCode: 204},
+		{method: "DELETE", token: tokens[1], chirpId: "2", wantCode: 404},
+	}
+
+	for i, c := range changeCases {
+		t.Run(fmt.Sprintf("Scheduled Chirps Change Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(c.method, "/api/chirps/scheduled/"+c.chirpId, strings.NewReader(c.body))
+			req.SetPathValue("chirpId", c.chirpId)
+			req.Header.Set("Authorization", "Bearer "+c.token)
+
+			if c.method == "PUT" {
+				cfg.putScheduledChirpHandler(w, req)
+			} else {
+				cfg.deleteScheduledChirpHandler(w, req)
+			}
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Scheduled Chirps Publish Test", func(t *testing.T) {
+		published, next := cfg.publishScheduledChirps(inOneHour)
+		if published != 0 || !next.Equal(inTwoHours) {
+			t.Errorf("Test failed (before due): got %d published and next at %v, want %d and %v", published, next, 0, inTwoHours)
+		}
+
+		published, next = cfg.publishScheduledChirps(inTwoHours)
+		if published != 1 || !next.IsZero() {
+			t.Errorf("Test failed (due): got %d published and next at %v, want %d and none", published, next, 1)
+		}
+
+		chirp, err := db.ChirpById(1, 0)
+		if err != nil || chirp.Body != "Launch day, for real!" || chirp.Scheduled() {
+			t.Errorf("Test failed (published chirp): got %+v, %v", chirp, err)
+		}
+		if got := len(listScheduled(tokens[1])); got != 0 {
+			t.Errorf("Test failed (scheduled after publishing): got %d, want %d", got, 0)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
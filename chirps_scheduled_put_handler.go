@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// putScheduledChirpHandler Changes the body, the publish time or both of a chirp that was not published yet
+func (cfg *apiConfig) putScheduledChirpHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	chirpId, err := strconv.Atoi(r.PathValue("chirpId"))
+	if err != nil {
+		log.Printf("Provided scheduled chirp id is not valid: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp id")
+		return
+	}
+
+	author, err := cfg.DB.UserById(userId)
+	if err != nil {
+		log.Printf("Could not find chirp author with id %d: %q", userId, err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	allowed := cfg.entitlementsFor(author)
+	if !allowed.CanSchedule {
+		respondWithError(w, http.StatusForbidden, "Scheduling chirps requires Chirpy Red")
+		return
+	}
+
+	type scheduledParams struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+	params := scheduledParams{}
+	err = decodeChirp(w, r, &params)
+	if errors.Is(err, ErrInvalidEncoding) || errors.Is(err, ErrChirpTooLarge) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error decoding scheduled chirp: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode chirp")
+		return
+	}
+
+	scheduled, err := cfg.DB.ScheduledChirpById(chirpId, userId)
+	if err != nil {
+		respondWithScheduledError(w, err)
+		return
+	}
+
+	body := scheduled.Body
+	if params.Body != nil {
+		body, err = validateChirp(*params.Body, allowed.MaxChirpLength)
+		if err != nil {
+			respondWithChirpError(w, err)
+			return
+		}
+	}
+
+	publishAt := *scheduled.PublishAt
+	if params.PublishAt != nil {
+		if err := validatePublishAt(*params.PublishAt, time.Now()); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		publishAt = *params.PublishAt
+	}
+
+	chirp, err := cfg.DB.UpdateScheduledChirp(chirpId, userId, body, chirpEntities(body), publishAt)
+	if err != nil {
+		respondWithScheduledError(w, err)
+		return
+	}
+
+	cfg.wakePreviewFetcher()
+	cfg.wakeScheduler()
+	respondWithJSON(w, http.StatusOK, chirp)
+}
+
+func respondWithScheduledError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ChirpNotExists), errors.Is(err, database.ErrNotScheduled):
+		// Published chirps are not found here either, they are changed through the chirp endpoints
+		respondWithError(w, http.StatusNotFound, "Scheduled chirp does not exist")
+	default:
+		log.Printf("Error received trying to change scheduled chirp: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal error")
+	}
+}
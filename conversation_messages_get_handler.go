@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMessagesPage = 50
+	maxMessagesPage     = 100
+)
+
+// messagesPage NextBefore is the before parameter of the next, older page, it is left out on the last one
+type messagesPage struct {
+	Messages   []database.Message `json:"messages"`
+	NextBefore int                `json:"next_before,omitempty"`
+}
+
+func (cfg *apiConfig) getConversationMessagesHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	before, limit := 0, defaultMessagesPage
+	if beforeParam := r.URL.Query().Get("before"); beforeParam != "" {
+		before, err = strconv.Atoi(beforeParam)
+		if err != nil || before < 1 {
+			respondWithError(w, http.StatusBadRequest, "Provided before is not valid")
+			return
+		}
+	}
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxMessagesPage {
+			respondWithError(w, http.StatusBadRequest, "Provided limit is not valid")
+			return
+		}
+	}
+
+	messages, err := cfg.DB.Messages(r.PathValue("conversationId"), userId, before, limit)
+	if err != nil {
+		respondWithMessagingError(w, err, "Could not retrieve messages")
+		return
+	}
+
+	page := messagesPage{Messages: messages}
+	if len(messages) == limit && messages[len(messages)-1].Seq > 1 {
+		page.NextBefore = messages[len(messages)-1].Seq
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
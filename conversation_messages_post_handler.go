@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/rivo/uniseg"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxMessageLength Counted in grapheme clusters like chirps, but the same for every plan
+	maxMessageLength = 1000
+	// maxMessageBytes Bounds the stored size, see maxChirpBytes
+	maxMessageBytes = 16 << 10
+)
+
+// validateMessage Messages go through the same normalization as chirps, bad words are left alone
+func validateMessage(body string) (string, error) {
+
+	body, err := normalizeChirp(body)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(body) == "" {
+		return "", errors.New("message can not be empty")
+	}
+	if len(body) > maxMessageBytes {
+		return "", fmt.Errorf("a message can have at most %d bytes", maxMessageBytes)
+	}
+	if length := uniseg.GraphemeClusterCount(body); length > maxMessageLength {
+		return "", fmt.Errorf("message length (%d) exceeds limit (%d)", length, maxMessageLength)
+	}
+
+	return body, nil
+}
+
+func (cfg *apiConfig) postConversationMessagesHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	params := parameters{}
+	err = decodeChirp(w, r, &params)
+	if err != nil && !errors.Is(err, ErrInvalidEncoding) && !errors.Is(err, ErrChirpTooLarge) {
+		log.Printf("Error decoding message: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode message")
+		return
+	}
+	if err == nil {
+		params.Body, err = validateMessage(params.Body)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sender, ok := cfg.messageSender(w, userId)
+	if !ok {
+		return
+	}
+
+	messageId, err := newDeliveryId()
+	if err != nil {
+		log.Printf("Could not create message id: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not send message")
+		return
+	}
+
+	message, err := cfg.DB.SendMessage(database.Message{
+		Id:             messageId,
+		ConversationId: r.PathValue("conversationId"),
+		SenderId:       userId,
+		Body:           params.Body,
+		CreatedAt:      time.Now().UTC(),
+	}, cfg.entitlementsFor(sender).CanMessageAnyone)
+	if err != nil {
+		respondWithMessagingError(w, err, "Could not send message")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, message)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// postConversationReadHandler Marks messages as read up to seq, or all of them when the body is empty
+func (cfg *apiConfig) postConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	type parameters struct {
+		Seq int `json:"seq"`
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("Error decoding read receipt: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode read receipt")
+		return
+	}
+	if params.Seq < 0 {
+		respondWithError(w, http.StatusBadRequest, "Provided seq is not valid")
+		return
+	}
+
+	conversation, err := cfg.DB.MarkConversationRead(r.PathValue("conversationId"), userId, params.Seq, time.Now().UTC())
+	if err != nil {
+		respondWithMessagingError(w, err, "Could not mark conversation as read")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, conversation)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (cfg *apiConfig) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	conversations, err := cfg.DB.ConversationsOf(userId)
+	if err != nil {
+		log.Printf("Could not list conversations of user with id %d: %q", userId, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve conversations")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, conversations)
+}
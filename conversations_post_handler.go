@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) postConversationsHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	type parameters struct {
+		UserId int `json:"user_id"`
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding conversation: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode conversation")
+		return
+	}
+
+	sender, ok := cfg.messageSender(w, userId)
+	if !ok {
+		return
+	}
+
+	conversationId, err := newDeliveryId()
+	if err != nil {
+		log.Printf("Could not create conversation id: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not start conversation")
+		return
+	}
+
+	conversation, created, err := cfg.DB.StartConversation(conversationId, userId, params.UserId,
+		cfg.entitlementsFor(sender).CanMessageAnyone, time.Now().UTC())
+	if err != nil {
+		respondWithMessagingError(w, err, "Could not start conversation")
+		return
+	}
+
+	if !created {
+		respondWithJSON(w, http.StatusOK, conversation)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, conversation)
+}
+
+// messageSender Messaging has the same email verification requirement as chirping
+func (cfg *apiConfig) messageSender(w http.ResponseWriter, userId int) (database.User, bool) {
+
+	sender, err := cfg.DB.UserById(userId)
+	if err != nil {
+		log.Printf("Could not find message sender with id %d: %q", userId, err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return database.User{}, false
+	}
+	if cfg.requireVerifiedEmail && !sender.EmailVerified {
+		log.Printf("User with id %d tried to send a message without a verified email", userId)
+		respondWithError(w, http.StatusForbidden, "Email address is not verified")
+		return database.User{}, false
+	}
+
+	return sender, true
+}
+
+func respondWithMessagingError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, database.ErrSelfMessage):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, database.UserNotExists):
+		respondWithError(w, http.StatusNotFound, "User does not exist")
+	case errors.Is(err, database.ConversationNotExists):
+		respondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, database.ErrBlocked):
+		respondWithError(w, http.StatusForbidden, "You can not message this user")
+	case errors.Is(err, database.ErrNotFollower):
+		respondWithError(w, http.StatusForbidden, "Only users who follow you can be messaged, Chirpy Red lifts this limit")
+	default:
+		log.Printf("%s: %q", msg, err)
+		respondWithError(w, http.StatusInternalServerError, msg)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestDirectMessages(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	users := []string{
+		`{"email": "alice@chirpy.com", "password": "tangerine-lantern-42"}`,
+		`{"email": "bob@chirpy.com", "password": "tangerine-lantern-42"}`,
+		`{"email": "red@chirpy.com", "password": "crimson-harbor-97"}`,
+	}
+	tokens := make([]string, len(users))
+	for i, user := range users {
+		createW := httptest.NewRecorder()
+		cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+		loginW := httptest.NewRecorder()
+		cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+	}
+
+	_, err = db.UpdateSubscription(3, database.SubscriptionChange{
+		Event:     "user.upgraded",
+		Status:    database.SubscriptionActive,
+		PeriodEnd: time.Now().Add(time.Hour),
+		At:        time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Could not upgrade user to chirpy red: %q", err)
+	}
+
+	start := func(token string, userId int) (database.ConversationSummary, int) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/conversations", strings.NewReader(fmt.Sprintf(`{"user_id": %d}`, userId)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.postConversationsHandler(w, req)
+
+		conversation := database.ConversationSummary{}
+		_ = json.NewDecoder(w.Body).Decode(&conversation)
+		return conversation, w.Code
+	}
+	send := func(token, conversationId, body string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/conversations/"+conversationId+"/messages", strings.NewReader(body))
+		req.SetPathValue("conversationId", conversationId)
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.postConversationMessagesHandler(w, req)
+		return w.Code
+	}
+
+	startCases := []struct {
+		token    string
+		userId   int
+		wantCode int
+	}{
+		{token: tokens[0], userId: 1, wantCode: 400},
+		{token: tokens[0], userId: 9, wantCode: 404},
+		// Bob does not follow Alice yet
+		{token: tokens[0], userId: 2, wantCode: 403},
+		// Red users can message anyone
+		{token: tokens[2], userId: 1, wantCode: 201},
+		{token: tokens[2], userId: 1, wantCode: 200},
+		// Being messaged by a red user does not let Alice message them back
+		{token: tokens[0], userId: 3, wantCode: 403},
+	}
+
+	for i, c := range startCases {
+		t.Run(fmt.Sprintf("Direct Messages Start Test Case %d", i), func(t *testing.T) {
+			if _, got := start(c.token, c.userId); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	if err := db.FollowUser(2, 1, time.Now()); err != nil {
+		t.Fatalf("Could not follow user: %q", err)
+	}
+	conversation, code := start(tokens[0], 2)
+	if code != 201 {
+		t.Fatalf("Could not start conversation: got %d, want %d", code, 201)
+	}
+
+	sendCases := []struct {
+		token    string
+		body     string
+		wantCode int
+	}{
+		{token: tokens[0], body: `{"body": "hi bob"}`, wantCode: 201},
+		{token: tokens[0], body: `{"body": "are you there?"}`, wantCode: 201},
+		{token: tokens[0], body: `{"body": "   "}`, wantCode: 400},
+		{token: tokens[0], body: fmt.Sprintf(`{"body": %q}`, strings.Repeat("a", maxMessageLength+1)), wantCode: 400},
+		{token: tokens[0], body: fmt.Sprintf(`{"body": %q}`, "a"+strings.Repeat("\u0301", maxMessageBytes)), wantCode: 400},
+		// Alice does not follow Bob back, so he can not answer her
+		{token: tokens[1], body: `{"body": "hi alice"}`, wantCode: 403},
+		{token: tokens[2], body: `{"body": "let me in"}`, wantCode: 404},
+	}
+
+	for i, c := range sendCases {
+		t.Run(fmt.Sprintf("Direct Messages Send Test Case %d", i), func(t *testing.T) {
+			if got := send(c.token, conversation.Id, c.body); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Direct Messages Unread Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/conversations", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+		cfg.getConversationsHandler(w, req)
+
+		conversations := []database.ConversationSummary{}
+		_ = json.NewDecoder(w.Body).Decode(&conversations)
+		if len(conversations) != 1 || conversations[0].Unread != 2 {
+			t.Errorf("Test failed (unread): got %+v, want one conversation with 2 unread", conversations)
+		}
+	})
+
+	t.Run("Direct Messages History Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/conversations/"+conversation.Id+"/messages?limit=1", nil)
+		req.SetPathValue("conversationId", conversation.Id)
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+		cfg.getConversationMessagesHandler(w, req)
+
+		page := messagesPage{}
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		if len(page.Messages) != 1 || page.Messages[0].Body != "are you there?" || page.NextBefore != 2 {
+			t.Errorf("Test failed (first page): got %+v", page)
+		}
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/conversations/"+conversation.Id+"/messages?limit=1&before=2", nil)
+		req.SetPathValue("conversationId", conversation.Id)
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+		cfg.getConversationMessagesHandler(w, req)
+
+		page = messagesPage{}
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		if len(page.Messages) != 1 || page.Messages[0].Body != "hi bob" || page.NextBefore != 0 {
+			t.Errorf("Test failed (last page): got %+v", page)
+		}
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/api/conversations/"+conversation.Id+"/messages", nil)
+		req.SetPathValue("conversationId", conversation.Id)
+		req.Header.Set("Authorization", "Bearer "+tokens[2])
+		cfg.getConversationMessagesHandler(w, req)
+		if w.Code != 404 {
+			t.Errorf("Test failed (outsider): got %d, want %d", w.Code, 404)
+		}
+	})
+
+	t.Run("Direct Messages Read Receipt Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/conversations/"+conversation.Id+"/read", strings.NewReader(`{"seq": 1}`))
+		req.SetPathValue("conversationId", conversation.Id)
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+		cfg.postConversationReadHandler(w, req)
+
+		read := database.ConversationSummary{}
+		_ = json.NewDecoder(w.Body).Decode(&read)
+		if read.Unread != 1 {
+			t.Errorf("Test failed (unread): got %d, want %d", read.Unread, 1)
+		}
+		for _, participant := range read.Participants {
+			if participant.UserId == 2 && (participant.LastReadSeq != 1 || participant.ReadAt == nil) {
+				t.Errorf("Test failed (receipt): got %+v", participant)
+			}
+		}
+	})
+
+	t.Run("Direct Messages Block Test", func(t *testing.T) {
+		if err := db.BlockUser(2, 1, time.Now()); err != nil {
+			t.Fatalf("Could not block user: %q", err)
+		}
+		if got := send(tokens[0], conversation.Id, `{"body": "still there?"}`); got != 403 {
+			t.Errorf("Test failed (blocked): got %d, want %d", got, 403)
+		}
+		if _, got := start(tokens[2], 2); got != 201 {
+			t.Errorf("Test failed (unrelated): got %d, want %d", got, 201)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
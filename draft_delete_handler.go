@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (cfg *apiConfig) deleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	err = cfg.DB.DeleteDraft(r.PathValue("draftId"), userId)
+	if err != nil {
+		if errors.Is(err, database.DraftNotExists) {
+			respondWithError(w, http.StatusNotFound, "Draft does not exist")
+			return
+		}
+		log.Printf("Could not delete draft: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal error")
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// postDraftPublishHandler Turns the draft into a chirp, it has to pass every check a new chirp does
+func (cfg *apiConfig) postDraftPublishHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	author, ok := cfg.chirpAuthor(w, userId)
+	if !ok {
+		return
+	}
+
+	// The body is optional, it is only needed to schedule the chirp or to limit who can see it
+	type publishParams struct {
+		PublishAt  *time.Time `json:"publish_at"`
+		Visibility string     `json:"visibility"`
+	}
+	params := publishParams{}
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("Error decoding publish parameters: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode publish parameters")
+		return
+	}
+
+	draftId := r.PathValue("draftId")
+	draft, err := cfg.DB.DraftById(draftId, userId)
+	if err != nil {
+		if errors.Is(err, database.DraftNotExists) {
+			respondWithError(w, http.StatusNotFound, "Draft does not exist")
+			return
+		}
+		log.Printf("Could not load draft: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Internal error")
+		return
+	}
+
+	newChirp, ok := cfg.newChirp(w, author, draft.Body, draft.MediaIds, params.PublishAt, params.Visibility)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.DB.PublishDraft(draftId, newChirp)
+	if err != nil {
+		cfg.chirpLimiter.refund(author.Id)
+	}
+	if errors.Is(err, database.DraftNotExists) {
+		// Published or deleted from another device in the meantime
+		respondWithError(w, http.StatusNotFound, "Draft does not exist")
+		return
+	}
+	cfg.respondWithNewChirp(w, chirp, err)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) putDraftHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := draftParams{}
+	err = decodeChirp(w, r, &params)
+	if err != nil && !errors.Is(err, ErrInvalidEncoding) && !errors.Is(err, ErrChirpTooLarge) {
+		log.Printf("Error decoding draft: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode draft")
+		return
+	}
+	if err == nil {
+		params.Body, err = validateDraft(params)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.Version <= 0 {
+		respondWithError(w, http.StatusBadRequest, "version is required")
+		return
+	}
+
+	draft, err := cfg.DB.UpdateDraft(r.PathValue("draftId"), userId, params.Body, params.MediaIds, params.Version, time.Now().UTC())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.DraftNotExists):
+			respondWithError(w, http.StatusNotFound, "Draft does not exist")
+		case errors.Is(err, database.ErrDraftConflict):
+			respondWithError(w, http.StatusConflict, "Draft was changed on another device, fetch it again before saving")
+		default:
+			log.Printf("Could not update draft: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not save draft")
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, draft)
+}
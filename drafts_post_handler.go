@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDraftBytes Drafts are not held to the chirp length limit, this only keeps them from growing without bound
+	maxDraftBytes    = 10_000
+	maxDraftsPerUser = 100
+)
+
+type draftParams struct {
+	Body     string   `json:"body"`
+	MediaIds []string `json:"media_ids"`
+	// Version The version the client edited, required when saving so conflicting changes are rejected
+	Version int `json:"version"`
+}
+
+// validateDraft Drafts can be longer than a chirp, but they have to be text that could become one
+func validateDraft(params draftParams) (string, error) {
+
+	body, err := normalizeChirp(params.Body)
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxDraftBytes {
+		return "", fmt.Errorf("a draft can have at most %d bytes", maxDraftBytes)
+	}
+	if len(params.MediaIds) > maxChirpMedia {
+		return "", fmt.Errorf("a chirp can have at most %d media", maxChirpMedia)
+	}
+
+	return body, nil
+}
+
+func (cfg *apiConfig) postDraftsHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := draftParams{}
+	err = decodeChirp(w, r, &params)
+	if err != nil && !errors.Is(err, ErrInvalidEncoding) && !errors.Is(err, ErrChirpTooLarge) {
+		log.Printf("Error decoding draft: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode draft")
+		return
+	}
+	if err == nil {
+		params.Body, err = validateDraft(params)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	draftId, err := newDeliveryId()
+	if err != nil {
+		log.Printf("Could not create draft id: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not save draft")
+		return
+	}
+
+	draft, err := cfg.DB.CreateDraft(database.Draft{
+		Id:        draftId,
+		OwnerId:   userId,
+		Body:      params.Body,
+		MediaIds:  params.MediaIds,
+		CreatedAt: time.Now().UTC(),
+	}, maxDraftsPerUser)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrTooManyDrafts):
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("You can have at most %d drafts", maxDraftsPerUser))
+		case errors.Is(err, database.UserNotExists):
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		default:
+			log.Printf("Could not save draft: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not save draft")
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, draft)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestDraftsHandlers(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	users := []string{
+		`{"email": "writer@chirpy.com", "password": "tangerine-lantern-42"}`,
+		`{"email": "snoop@chirpy.com", "password": "crimson-harbor-97"}`,
+	}
+	tokens := make([]string, len(users))
+	for i, user := range users {
+		createW := httptest.NewRecorder()
+		cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+		loginW := httptest.NewRecorder()
+		cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+	}
+
+	request := func(method, path, draftId, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetPathValue("draftId", draftId)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		switch {
+		case strings.HasSuffix(path, "/publish"):
+			cfg.postDraftPublishHandler(w, req)
+		case method == "POST":
+			cfg.postDraftsHandler(w, req)
+		case method == "GET":
+			cfg.getDraftsHandler(w, req)
+		case method == "PUT":
+			cfg.putDraftHandler(w, req)
+		case method == "DELETE":
+			cfg.deleteDraftHandler(w, req)
+		}
+		return w
+	}
+
+	longBody := strings.Repeat("a", 500)
+	draftIds := make([]string, 0)
+
+	postCases := []struct {
+		body     string
+		wantCode int
+	}{
+		// Drafts are not held to the chirp length limit
+		{body: fmt.Sprintf(`{"body": %q}`, longBody), wantCode: 201},
+		{body: `{"body": "Second thoughts"}`, wantCode: 201},
+		{body: fmt.Sprintf(`{"body": %q}`, strings.Repeat("a", maxDraftBytes+1)), wantCode: 400},
+		{body: "{\"body\": \"Broken \xff draft\"}", wantCode: 400},
+	}
+
+	for i, c := range postCases {
+		t.Run(fmt.Sprintf("Drafts Post Test Case %d", i), func(t *testing.T) {
+			w := request("POST", "/api/drafts", "", tokens[0], c.body)
+			if w.Code != c.wantCode {
+				t.Fatalf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+			if w.Code == 201 {
+				draft := database.Draft{}
+				_ = json.NewDecoder(w.Body).Decode(&draft)
+				if draft.Version != 1 {
+					t.Errorf("Test failed (version): got %d, want %d", draft.Version, 1)
+				}
+				draftIds = append(draftIds, draft.Id)
+			}
+		})
+	}
+
+	if len(draftIds) != 2 {
+		t.Fatalf("Test failed (drafts): got %d, want %d", len(draftIds), 2)
+	}
+
+	cases := []struct {
+		method   string
+		path     string
+		draftId  string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		// A device saving version 1 while another already saved over it gets a conflict
+		{method: "PUT", draftId: draftIds[0], token: tokens[0], body: fmt.Sprintf(`{"body": %q, "version": 1}`, longBody+"!"), wantCode: 200},
+		{method: "PUT", draftId: draftIds[0], token: tokens[0], body: `{"body": "Stale", "version": 1}`, wantCode: 409},
+		{method: "PUT", draftId: draftIds[0], token: tokens[0], body: `{"body": "Blind overwrite"}`, wantCode: 400, wantBody: `{"error":"version is required"}`},
+		{method: "PUT", draftId: draftIds[0], token: tokens[1], body: `{"body": "Not mine", "version": 2}`, wantCode: 404},
+		{method: "GET", path: "/api/drafts", token: tokens[1], wantCode: 200, wantBody: `[]`},
+		// Publishing runs the chirp validation, so the long draft stays a draft
+		{method: "POST", path: "/api/drafts/publish", draftId: draftIds[0], token: tokens[0], wantCode: 400,
+			wantBody: `{"error":"chirp length exceeds limit","length":501,"limit":140}`},
+		{method: "POST", path: "/api/drafts/publish", draftId: draftIds[0], token: tokens[1], wantCode: 404},
+		{method: "PUT", draftId: draftIds[0], token: tokens[0], body: `{"body": "Short and sweet, with a kerfuffle", "version": 2}`, wantCode: 200},
+		{method: "POST", path: "/api/drafts/publish", draftId: draftIds[0], token: tokens[0], wantCode: 201,
+			wantBody: `{"body":"Short and sweet, with a ****","id":1,"author_id":1,"visibility":"public"}`},
+		{method: "POST", path: "/api/drafts/publish", draftId: draftIds[0], token: tokens[0], wantCode: 404},
+		{method: "DELETE", draftId: draftIds[1], token: tokens[1], wantCode: 404},
+		{method: "DELETE", draftId: draftIds[1], token: tokens[0], wantCode: 204},
+		{method: "GET", path: "/api/drafts", token: tokens[0], wantCode: 200, wantBody: `[]`},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Drafts Handlers Test Case %d", i), func(t *testing.T) {
+			path := c.path
+			if path == "" {
+				path = "/api/drafts/" + c.draftId
+			}
+			w := request(c.method, path, c.draftId, c.token, c.body)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); c.wantBody != "" && got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Concurrent Publish Test", func(t *testing.T) {
+		w := request("POST", "/api/drafts", "", tokens[0], `{"body": "Tapped publish twice"}`)
+		draft := database.Draft{}
+		_ = json.NewDecoder(w.Body).Decode(&draft)
+
+		var wg sync.WaitGroup
+		codes := make([]int, 5)
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i] = request("POST", "/api/drafts/publish", draft.Id, tokens[0], "").Code
+			}()
+		}
+		wg.Wait()
+
+		slices.Sort(codes)
+		if want := []int{201, 404, 404, 404, 404}; !slices.Equal(codes, want) {
+			t.Errorf("Test failed (codes): got %v, want %v", codes, want)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
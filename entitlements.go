@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type plan string
+
+const (
+	planFree plan = "free"
+	planRed  plan = "red"
+)
+
+// entitlements What a plan allows, a zero ChirpsPerHour means no rate limit
+type entitlements struct {
+	MaxChirpLength int
+	CanEdit        bool
+	CanSchedule    bool
+	ChirpsPerHour  int
+	// CanMessageAnyone Without it users can only message people who follow them
+	CanMessageAnyone bool
+}
+
+// defaultPlans Used when the config does not set any
+var defaultPlans = map[plan]entitlements{
+	planFree: {MaxChirpLength: 140, ChirpsPerHour: 30},
+	planRed:  {MaxChirpLength: 280, CanEdit: true, CanSchedule: true, ChirpsPerHour: 120, CanMessageAnyone: true},
+}
+
+// planOf The stored IsChirpyRed flag lags behind until the janitor expires the membership, so the subscription decides
+func planOf(user database.User, now time.Time) plan {
+	if user.Subscription.Active(now) {
+		return planRed
+	}
+	return planFree
+}
+
+func (cfg *apiConfig) entitlementsFor(user database.User) entitlements {
+	plans := cfg.plans
+	if plans == nil {
+		plans = defaultPlans
+	}
+	return plans[planOf(user, time.Now())]
+}
+
+// chirpRateLimiter Sliding window of the chirps every user posted in the last hour, kept in memory only.
+// The zero value is ready to use.
+type chirpRateLimiter struct {
+	mu    sync.Mutex
+	posts map[int][]time.Time
+}
+
+// allow Records the chirp when it is within the limit, otherwise returns how long until it would be
+func (l *chirpRateLimiter) allow(userId, perHour int, now time.Time) (bool, time.Duration) {
+	if perHour <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.posts == nil {
+		l.posts = make(map[int][]time.Time)
+	}
+
+	windowStart := now.Add(-time.Hour)
+	recent := l.posts[userId][:0]
+	for _, postedAt := range l.posts[userId] {
+		if postedAt.After(windowStart) {
+			recent = append(recent, postedAt)
+		}
+	}
+
+	if len(recent) >= perHour {
+		l.posts[userId] = recent
+		return false, recent[0].Sub(windowStart)
+	}
+
+	l.posts[userId] = append(recent, now)
+	return true, 0
+}
+
+// refund Gives back the latest chirp of the user, for posts that could not be saved after all
+func (l *chirpRateLimiter) refund(userId int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	posts := l.posts[userId]
+	if len(posts) == 0 {
+		return
+	}
+	l.posts[userId] = posts[:len(posts)-1]
+}
+
+func (cfg *apiConfig) checkChirpRate(w http.ResponseWriter, userId int, allowed entitlements) bool {
+
+	ok, retryAfter := cfg.chirpLimiter.allow(userId, allowed.ChirpsPerHour, time.Now())
+	if ok {
+		return true
+	}
+
+	log.Printf("User with id %d reached the limit of %d chirps per hour", userId, allowed.ChirpsPerHour)
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("You can post up to %d chirps per hour", allowed.ChirpsPerHour))
+	return false
+}
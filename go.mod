@@ -3,8 +3,12 @@ module github.com/benjamin-vq/chirpy
 go 1.22.3
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/joho/godotenv v1.5.1
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/crypto v0.24.0
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
+	golang.org/x/net v0.26.0
+	golang.org/x/text v0.16.0
+)
 
-)
\ No newline at end of file
+require golang.org/x/sys v0.21.0 // indirect
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrMismatchedPassword = errors.New("password does not match hash")
+var ErrInvalidHash = errors.New("password hash is malformed")
+
+// Argon2idHasher Hashes in the PHC string format: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idHasher OWASP recommended minimum parameters
+func DefaultArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		Memory:      19 * 1024,
+		Iterations:  2,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Bounds for configured parameters, low enough for small servers and high enough to stop typos from exhausting memory
+const (
+	minArgon2idMemory     = 1024
+	maxArgon2idMemory     = 4 * 1024 * 1024
+	maxArgon2idIterations = 64
+)
+
+// Validate Rejects parameters that would make hashes trivial to crack or hashing itself unaffordable
+func (h Argon2idHasher) Validate() error {
+	if h.Memory < minArgon2idMemory || h.Memory > maxArgon2idMemory {
+		return fmt.Errorf("argon2id memory should be between %d and %d KiB, got %d", minArgon2idMemory, maxArgon2idMemory, h.Memory)
+	}
+	if h.Iterations < 1 || h.Iterations > maxArgon2idIterations {
+		return fmt.Errorf("argon2id iterations should be between 1 and %d, got %d", maxArgon2idIterations, h.Iterations)
+	}
+	if h.Parallelism < 1 {
+		return errors.New("argon2id parallelism should be at least 1")
+	}
+	if h.Memory < 8*uint32(h.Parallelism) {
+		return fmt.Errorf("argon2id memory should be at least 8 KiB per lane, got %d KiB for %d lanes", h.Memory, h.Parallelism)
+	}
+	if h.SaltLength < 16 || h.KeyLength < 16 {
+		return errors.New("argon2id salt and key should be at least 16 bytes")
+	}
+	return nil
+}
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+var argon2Encoding = base64.RawStdEncoding
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+
+	salt := make([]byte, h.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		log.Printf("Could not generate salt: %q", err)
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations,
+		h.Parallelism, argon2Encoding.EncodeToString(salt), argon2Encoding.EncodeToString(key)), nil
+}
+
+// Verify Uses the parameters stored in hashed, not the ones of the hasher
+func (h Argon2idHasher) Verify(hashed, password string) error {
+	parsed, err := parseArgon2idHash(hashed)
+	if err != nil {
+		return err
+	}
+
+	key := argon2.IDKey([]byte(password), parsed.salt, parsed.iterations, parsed.memory, parsed.parallelism,
+		uint32(len(parsed.key)))
+
+	if subtle.ConstantTimeCompare(key, parsed.key) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+func (h Argon2idHasher) Identifies(hashed string) bool {
+	return strings.HasPrefix(hashed, "$argon2id$")
+}
+
+func (h Argon2idHasher) Outdated(hashed string) bool {
+	parsed, err := parseArgon2idHash(hashed)
+	if err != nil {
+		return true
+	}
+
+	return parsed.version != argon2.Version ||
+		parsed.memory < h.Memory ||
+		parsed.iterations < h.Iterations ||
+		parsed.parallelism < h.Parallelism ||
+		uint32(len(parsed.salt)) < h.SaltLength ||
+		uint32(len(parsed.key)) < h.KeyLength
+}
+
+func parseArgon2idHash(hashed string) (argon2idHash, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2idHash{}, ErrInvalidHash
+	}
+
+	parsed := argon2idHash{}
+	_, err := fmt.Sscanf(parts[2], "v=%d", &parsed.version)
+	if err != nil {
+		return argon2idHash{}, ErrInvalidHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &parsed.memory, &parsed.iterations, &parsed.parallelism)
+	if err != nil {
+		return argon2idHash{}, ErrInvalidHash
+	}
+
+	parsed.salt, err = argon2Encoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2idHash{}, ErrInvalidHash
+	}
+
+	parsed.key, err = argon2Encoding.DecodeString(parts[5])
+	if err != nil || len(parsed.key) == 0 {
+		return argon2idHash{}, ErrInvalidHash
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testArgon2idHasher Small parameters so the tests stay fast, never use them outside of tests
+var testArgon2idHasher = Argon2idHasher{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasherRoundTrip(t *testing.T) {
+
+	hashed, err := testArgon2idHasher.Hash("tangerine-lantern-42")
+	if err != nil {
+		t.Fatalf("Could not hash password: %q", err)
+	}
+
+	if !strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Test failed (format): got %s", hashed)
+	}
+	if !testArgon2idHasher.Identifies(hashed) {
+		t.Errorf("Test failed (identifies): got false, want true")
+	}
+
+	cases := []struct {
+		password string
+		want     error
+	}{
+		{password: "tangerine-lantern-42", want: nil},
+		{password: "tangerine-lantern-43", want: ErrMismatchedPassword},
+		{password: "", want: ErrMismatchedPassword},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Argon2id Round Trip Test Case %d", i), func(t *testing.T) {
+			// Verify must use the stored parameters, not the ones of the hasher
+			got := DefaultArgon2idHasher().Verify(hashed, c.password)
+			if !errors.Is(got, c.want) {
+				t.Errorf("Test failed: got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestArgon2idHasherMalformed(t *testing.T) {
+
+	cases := []string{
+		"",
+		"not a hash",
+		"$2a$10$abcdefghijklmnopqrstuuN8C9x0r1I2W3o4p5Q6r7S8t9U0v1W2",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"$argon2id$v=nineteen$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$not*base64$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+	}
+
+	for i, hashed := range cases {
+		t.Run(fmt.Sprintf("Argon2id Malformed Test Case %d", i), func(t *testing.T) {
+			err := testArgon2idHasher.Verify(hashed, "tangerine-lantern-42")
+			if !errors.Is(err, ErrInvalidHash) {
+				t.Errorf("Test failed (verify): got %v, want %v", err, ErrInvalidHash)
+			}
+			if !testArgon2idHasher.Outdated(hashed) {
+				t.Errorf("Test failed (outdated): got false, want true")
+			}
+		})
+	}
+}
+
+func TestArgon2idHasherOutdated(t *testing.T) {
+
+	hashed, err := testArgon2idHasher.Hash("tangerine-lantern-42")
+	if err != nil {
+		t.Fatalf("Could not hash password: %q", err)
+	}
+
+	cases := []struct {
+		hasher Argon2idHasher
+		want   bool
+	}{
+		{hasher: testArgon2idHasher, want: false},
+		{hasher: Argon2idHasher{Memory: 32, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}, want: false},
+		{hasher: Argon2idHasher{Memory: 128, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, want: true},
+		{hasher: Argon2idHasher{Memory: 64, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}, want: true},
+		{hasher: Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 2, SaltLength: 16, KeyLength: 32}, want: true},
+		{hasher: Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 32, KeyLength: 32}, want: true},
+		{hasher: Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 64}, want: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Argon2id Outdated Test Case %d", i), func(t *testing.T) {
+			if got := c.hasher.Outdated(hashed); got != c.want {
+				t.Errorf("Test failed: got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestArgon2idHasherValidate(t *testing.T) {
+
+	cases := []struct {
+		hasher  Argon2idHasher
+		wantErr bool
+	}{
+		{hasher: DefaultArgon2idHasher(), wantErr: false},
+		{hasher: Argon2idHasher{Memory: 0, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}, wantErr: true},
+		{hasher: Argon2idHasher{Memory: 19 * 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32}, wantErr: true},
+		{hasher: Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 0, SaltLength: 16, KeyLength: 32}, wantErr: true},
+		{hasher: Argon2idHasher{Memory: 64 * 1024 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}, wantErr: true},
+		{hasher: Argon2idHasher{Memory: 19 * 1024, Iterations: 1000, Parallelism: 1, SaltLength: 16, KeyLength: 32}, wantErr: true},
+		{hasher: Argon2idHasher{Memory: 1024, Iterations: 2, Parallelism: 255, SaltLength: 16, KeyLength: 32}, wantErr: true},
+		{hasher: Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 4, KeyLength: 32}, wantErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Argon2id Validate Test Case %d", i), func(t *testing.T) {
+			err := c.hasher.Validate()
+			if (err != nil) != c.wantErr {
+				t.Errorf("Test failed: got %v, want error %t", err, c.wantErr)
+			}
+		})
+	}
+}
@@ -1,17 +1,63 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+var ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+
+// Hasher Password hashing algorithm. Hashes are self describing, they carry the algorithm and the parameters
+// used to produce them so they can still be verified after the configured hasher changes.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(hashed, password string) error
+	// Identifies Whether hashed was produced by this algorithm, regardless of its parameters
+	Identifies(hashed string) bool
+	// Outdated Whether hashed, produced by this algorithm, uses weaker parameters than the configured ones
+	Outdated(hashed string) bool
+}
+
+var passwordHasher Hasher = DefaultArgon2idHasher()
+
+// knownHashers Every algorithm that may be found in the database, only used to verify
+var knownHashers = []Hasher{
+	Argon2idHasher{},
+	BcryptHasher{},
+}
+
+// SetPasswordHasher Meant to be called once on startup, before serving requests.
+func SetPasswordHasher(hasher Hasher) {
+	passwordHasher = hasher
+}
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return passwordHasher.Hash(password)
+}
 
-	if err != nil {
-		return "", err
+func ComparePasswordHash(hashed, password string) error {
+	for _, hasher := range knownHashers {
+		if hasher.Identifies(hashed) {
+			return hasher.Verify(hashed, password)
+		}
 	}
 
-	return string(bytes), nil
+	log.Printf("Could not identify password hash algorithm: %.10q", hashed)
+	return ErrUnknownHashAlgorithm
 }
 
-func ComparePasswordHash(hashed, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+// NeedsRehash Whether hashed should be replaced with a hash from the configured hasher, only known once the
+// password was verified.
+func NeedsRehash(hashed string) bool {
+	return !passwordHasher.Identifies(hashed) || passwordHasher.Outdated(hashed)
+}
+
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
 }
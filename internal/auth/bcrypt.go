@@ -0,0 +1,38 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// BcryptHasher Legacy algorithm, only the first 72 bytes of a password are used so longer ones are rejected.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
+func (h BcryptHasher) Verify(hashed, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password))
+}
+
+func (h BcryptHasher) Identifies(hashed string) bool {
+	return hasAnyPrefix(hashed, "$2a$", "$2b$", "$2y$")
+}
+
+func (h BcryptHasher) Outdated(hashed string) bool {
+	cost, err := bcrypt.Cost([]byte(hashed))
+	return err != nil || cost < h.cost()
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"strconv"
+	"time"
+)
+
+const twoFactorChallengeAudience = "2fa-challenge"
+
+// CreateChallengeToken Issued after a correct password when the user has two-factor authentication enabled.
+func CreateChallengeToken(userId int, jwtSecret string, expireAfter time.Duration) (string, error) {
+
+	now := time.Now().UTC()
+	claims := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Audience:  jwt.ClaimStrings{twoFactorChallengeAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expireAfter)),
+		Subject:   strconv.Itoa(userId),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret))
+}
+
+func UserIdFromChallengeToken(token, jwtSecret string) (userId int, err error) {
+
+	claims := jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (any, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithAudience(twoFactorChallengeAudience), jwt.WithIssuer("chirpy"), jwt.WithValidMethods([]string{"HS256"}))
+
+	if err != nil {
+		log.Printf("Could not parse challenge token: %q", err)
+		return 0, err
+	}
+
+	userId, err = strconv.Atoi(claims.Subject)
+	if err != nil {
+		log.Printf("Invalid subject in challenge token: %q", err)
+		return 0, err
+	}
+
+	return userId, nil
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
@@ -48,6 +49,10 @@ func ValidateToken(token, jwtSecret string) (string, error) {
 		return "", errors.New("invalid token")
 	}
 
+	if err := ensureAccessToken(parsedJwt); err != nil {
+		return "", err
+	}
+
 	subject, err := parsedJwt.Claims.GetSubject()
 	if err != nil {
 		log.Printf("Could not get subject in parsed token: %q", err)
@@ -68,17 +73,28 @@ func ValidateToken(token, jwtSecret string) (string, error) {
 }
 
 func GenerateRefreshToken() (string, error) {
+	return GenerateOpaqueToken()
+}
+
+// GenerateOpaqueToken Random token meant to be stored server side, like refresh or password reset tokens.
+func GenerateOpaqueToken() (string, error) {
 
 	bytes := make([]byte, 32)
 	_, err := rand.Read(bytes)
 	if err != nil {
-		log.Printf("Could not generate refresh token: %q", err)
+		log.Printf("Could not generate opaque token: %q", err)
 		return "", err
 	}
 
 	return hex.EncodeToString(bytes), nil
 }
 
+// HashOpaqueToken Opaque tokens are high entropy, a plain SHA-256 is enough to avoid storing them as is.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func UserIdFromToken(token, jwtSecret string) (userId int, err error) {
 
 	claims := jwt.RegisteredClaims{}
@@ -91,6 +107,10 @@ func UserIdFromToken(token, jwtSecret string) (userId int, err error) {
 		return 0, err
 	}
 
+	if err := ensureAccessToken(parsedJwt); err != nil {
+		return 0, err
+	}
+
 	subject, err := parsedJwt.Claims.GetSubject()
 	if err != nil {
 		log.Printf("Could not get subject in parsed token: %q", err)
@@ -105,3 +125,19 @@ func UserIdFromToken(token, jwtSecret string) (userId int, err error) {
 
 	return userId, nil
 }
+
+// ensureAccessToken Tokens issued for another purpose carry an audience, they must never be accepted as access tokens.
+func ensureAccessToken(parsedJwt *jwt.Token) error {
+	audience, err := parsedJwt.Claims.GetAudience()
+	if err != nil {
+		log.Printf("Could not get audience in parsed token: %q", err)
+		return err
+	}
+
+	if len(audience) != 0 {
+		log.Printf("Received a token for %v as an access token", audience)
+		return errors.New("not an access token")
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret The SHA-1 seed of RFC 6238 appendix B, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+
+	// RFC 6238 appendix B, truncated to the last six digits since only six digit codes are issued
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("TOTP Code Test Case %d", i), func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, time.Unix(c.unix, 0))
+			if err != nil {
+				t.Fatalf("Test failed (error): got %q, want nil", err)
+			}
+			if got != c.want {
+				t.Errorf("Test failed: got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+
+	codeAt := func(offset int64) string {
+		code, err := hotp(rfc6238Secret, step+offset)
+		if err != nil {
+			t.Fatalf("Could not generate code: %q", err)
+		}
+		return code
+	}
+
+	cases := []struct {
+		secret   string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{secret: rfc6238Secret, code: codeAt(0), wantStep: step, wantOk: true},
+		{secret: rfc6238Secret, code: " " + codeAt(0) + " ", wantStep: step, wantOk: true},
+		{secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: codeAt(0), wantStep: step, wantOk: true},
+		{secret: rfc6238Secret, code: codeAt(-1), wantStep: step - 1, wantOk: true},
+		{secret: rfc6238Secret, code: codeAt(1), wantStep: step + 1, wantOk: true},
+		{secret: rfc6238Secret, code: codeAt(-2), wantOk: false},
+		{secret: rfc6238Secret, code: codeAt(2), wantOk: false},
+		{secret: rfc6238Secret, code: "12345", wantOk: false},
+		{secret: rfc6238Secret, code: "", wantOk: false},
+		{secret: "not base32!", code: codeAt(0), wantOk: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Validate TOTP Test Case %d", i), func(t *testing.T) {
+			gotStep, gotOk := ValidateTOTP(c.secret, c.code, now)
+			if gotOk != c.wantOk {
+				t.Errorf("Test failed (ok): got %t, want %t", gotOk, c.wantOk)
+			}
+			if gotOk && gotStep != c.wantStep {
+				t.Errorf("Test failed (step): got %d, want %d", gotStep, c.wantStep)
+			}
+		})
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Could not generate recovery codes: %q", err)
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		t.Run(fmt.Sprintf("Normalize Recovery Code Test Case %d", i), func(t *testing.T) {
+			if len(code) != 9 || code[4] != '-' {
+				t.Errorf("Test failed (format): got %s, want xxxx-xxxx", code)
+			}
+			typed := " " + strings.ToUpper(code[:4]+code[5:]) + " "
+			if NormalizeRecoveryCode(typed) != NormalizeRecoveryCode(code) {
+				t.Errorf("Test failed (normalize): got %s, want %s", NormalizeRecoveryCode(typed), NormalizeRecoveryCode(code))
+			}
+			if seen[code] {
+				t.Errorf("Test failed, duplicated recovery code %s", code)
+			}
+			seen[code] = true
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"strconv"
+	"time"
+)
+
+const emailVerificationAudience = "email-verification"
+
+type emailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// CreateEmailVerificationToken Signed token proving ownership of email, it stops working if the user changes it.
+func CreateEmailVerificationToken(userId int, email, jwtSecret string, expireAfter time.Duration) (string, error) {
+
+	now := time.Now().UTC()
+	claims := emailVerificationClaims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expireAfter)),
+			Subject:   strconv.Itoa(userId),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtSecret))
+}
+
+func ParseEmailVerificationToken(token, jwtSecret string) (userId int, email string, err error) {
+
+	claims := emailVerificationClaims{}
+	_, err = jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (any, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithAudience(emailVerificationAudience), jwt.WithIssuer("chirpy"), jwt.WithValidMethods([]string{"HS256"}))
+
+	if err != nil {
+		log.Printf("Could not parse email verification token: %q", err)
+		return 0, "", err
+	}
+
+	userId, err = strconv.Atoi(claims.Subject)
+	if err != nil {
+		log.Printf("Invalid subject in email verification token: %q", err)
+		return 0, "", err
+	}
+
+	if claims.Email == "" {
+		return 0, "", errors.New("email verification token without email")
+	}
+
+	return userId, claims.Email, nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const webhookSignatureVersion = "v1="
+
+var ErrMissingSignature = errors.New("missing webhook signature or timestamp")
+var ErrSignatureMismatch = errors.New("webhook signature does not match")
+var ErrTimestampOutOfRange = errors.New("webhook timestamp is outside of the tolerance window")
+
+// SignWebhook Signs the timestamp together with the raw body so neither can be swapped on a captured request.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature The signature header may hold several comma separated signatures while the secret is rotated.
+func VerifyWebhookSignature(secret, signatureHeader, timestampHeader string, body []byte, now time.Time, tolerance time.Duration) error {
+
+	if signatureHeader == "" || timestampHeader == "" {
+		return ErrMissingSignature
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrTimestampOutOfRange
+	}
+
+	expected := []byte(SignWebhook(secret, timestamp, body))
+	for _, signature := range strings.Split(signatureHeader, ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(signature)), expected) {
+			return nil
+		}
+	}
+
+	return ErrSignatureMismatch
+}
+
+// ConstantTimeEqual Compares secrets without leaking how many leading bytes matched
+func ConstantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
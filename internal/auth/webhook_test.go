@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+
+	// HMAC-SHA256 of "1700000000.{\"event\":\"user.upgraded\"}" keyed with "secret"
+	want := "v1=f5c3d30e389c8212ba710d9804bde41faa48f10e05ae15dc3ab04cd78b911f3c"
+	got := SignWebhook("secret", 1700000000, []byte(`{"event":"user.upgraded"}`))
+	if got != want {
+		t.Errorf("Test failed: got %s, want %s", got, want)
+	}
+
+	if SignWebhook("secret", 1700000000, []byte("a")) == SignWebhook("secret", 1700000001, []byte("a")) {
+		t.Errorf("Test failed, the timestamp is not part of the signature")
+	}
+	if SignWebhook("secret", 1700000000, []byte("a")) == SignWebhook("other", 1700000000, []byte("a")) {
+		t.Errorf("Test failed, the secret is not part of the signature")
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":1}}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := SignWebhook("secret", now.Unix(), body)
+
+	cases := []struct {
+		signature string
+		timestamp string
+		body      []byte
+		now       time.Time
+		want      error
+	}{
+		{signature: signature, timestamp: timestamp, body: body, now: now, want: nil},
+		// Several signatures are sent while the secret is rotated
+		{signature: SignWebhook("old", now.Unix(), body) + ", " + signature, timestamp: timestamp, body: body, now: now, want: nil},
+		{signature: signature, timestamp: timestamp, body: body, now: now.Add(4 * time.Minute), want: nil},
+		{signature: signature, timestamp: timestamp, body: body, now: now.Add(6 * time.Minute), want: ErrTimestampOutOfRange},
+		{signature: signature, timestamp: timestamp, body: body, now: now.Add(-6 * time.Minute), want: ErrTimestampOutOfRange},
+		{signature: signature, timestamp: timestamp, body: []byte(`{"event":"user.upgraded","data":{"user_id":2}}`), now: now, want: ErrSignatureMismatch},
+		{signature: SignWebhook("wrong", now.Unix(), body), timestamp: timestamp, body: body, now: now, want: ErrSignatureMismatch},
+		// A captured signature can not be replayed with a fresh timestamp
+		{signature: signature, timestamp: strconv.FormatInt(now.Unix()+1, 10), body: body, now: now, want: ErrSignatureMismatch},
+		{signature: "", timestamp: timestamp, body: body, now: now, want: ErrMissingSignature},
+		{signature: signature, timestamp: "", body: body, now: now, want: ErrMissingSignature},
+		{signature: signature, timestamp: "yesterday", body: body, now: now, want: ErrMissingSignature},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Verify Webhook Signature Test Case %d", i), func(t *testing.T) {
+			got := VerifyWebhookSignature("secret", c.signature, c.timestamp, c.body, c.now, 5*time.Minute)
+			if !errors.Is(got, c.want) {
+				t.Errorf("Test failed: got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
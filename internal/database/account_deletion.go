@@ -0,0 +1,203 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+// ChirpPolicy What happens to the chirps of a purged user
+type ChirpPolicy string
+
+const (
+	DeleteChirps    ChirpPolicy = "delete"
+	AnonymizeChirps ChirpPolicy = "anonymize"
+)
+
+type UserPurgeStats struct {
+	Users            int
+	DeletedChirps    int
+	AnonymizedChirps int
+	// MediaIds Blobs the caller has to remove from the blob store
+	MediaIds []string
+}
+
+// SoftDeleteUser Disables the account and ends every session, the data stays until the grace period ends.
+func (db *DB) SoftDeleteUser(userId int, now time.Time) error {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists || user.Deleted() {
+			return UserNotExists
+		}
+
+		user.DeletedAt = &now
+		dbStructure.Users[userId] = user
+
+		for rt, refreshToken := range dbStructure.RefreshTokens {
+			if refreshToken.UserId == userId {
+				delete(dbStructure.RefreshTokens, rt)
+			}
+		}
+
+		for hash, resetToken := range dbStructure.PasswordResetTokens {
+			if resetToken.UserId == userId {
+				delete(dbStructure.PasswordResetTokens, hash)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Soft deleted user with id %d", userId)
+	return nil
+}
+
+// RestoreUser Cancels a pending deletion
+func (db *DB) RestoreUser(userId int) error {
+
+	restored := false
+	err := db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if !user.Deleted() {
+			return errNoChange
+		}
+
+		user.DeletedAt = nil
+		dbStructure.Users[userId] = user
+		restored = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if restored {
+		log.Printf("Restored user with id %d", userId)
+	}
+	return nil
+}
+
+// PurgeDeletedUsers Permanently removes users deleted before deletedBefore along with everything derived from them.
+func (db *DB) PurgeDeletedUsers(deletedBefore time.Time, chirpPolicy ChirpPolicy) (UserPurgeStats, error) {
+
+	stats := UserPurgeStats{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		for id, user := range dbStructure.Users {
+			if user.Deleted() && user.DeletedAt.Before(deletedBefore) {
+				purgeUser(dbStructure, id, chirpPolicy, &stats)
+				stats.Users++
+			}
+		}
+
+		if stats.Users == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not purge deleted users: %q", err)
+		return UserPurgeStats{}, err
+	}
+
+	return stats, nil
+}
+
+// purgeUser Every record referencing the user has to be removed here.
+func purgeUser(dbStructure *DBStructure, userId int, chirpPolicy ChirpPolicy, stats *UserPurgeStats) {
+
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.AuthorId != userId {
+			continue
+		}
+		// Scheduled chirps were never published, there is nothing to keep
+		if chirpPolicy == AnonymizeChirps && !chirp.Scheduled() {
+			chirp.AuthorId = 0
+			chirp.MediaIds = nil
+			dbStructure.Chirps[id] = chirp
+			stats.AnonymizedChirps++
+			continue
+		}
+		dbStructure.removeChirp(id)
+		stats.DeletedChirps++
+	}
+
+	for rt, refreshToken := range dbStructure.RefreshTokens {
+		if refreshToken.UserId == userId {
+			delete(dbStructure.RefreshTokens, rt)
+		}
+	}
+
+	for hash, resetToken := range dbStructure.PasswordResetTokens {
+		if resetToken.UserId == userId {
+			delete(dbStructure.PasswordResetTokens, hash)
+		}
+	}
+
+	for key, follow := range dbStructure.Follows {
+		if follow.FollowerId == userId || follow.FolloweeId == userId {
+			delete(dbStructure.Follows, key)
+		}
+	}
+
+	for key, bookmark := range dbStructure.Bookmarks {
+		if bookmark.UserId == userId {
+			delete(dbStructure.Bookmarks, key)
+		}
+	}
+
+	for _, relations := range []map[string]Relation{dbStructure.Blocks, dbStructure.Mutes} {
+		for key, relation := range relations {
+			if relation.UserId == userId || relation.TargetId == userId {
+				delete(relations, key)
+			}
+		}
+	}
+
+	for id, media := range dbStructure.Media {
+		if media.OwnerId == userId {
+			delete(dbStructure.Media, id)
+			stats.MediaIds = append(stats.MediaIds, id)
+		}
+	}
+
+	for id, endpoint := range dbStructure.WebhookEndpoints {
+		if endpoint.OwnerId == userId {
+			dbStructure.deleteWebhookEndpoint(id)
+		}
+	}
+
+	for id, draft := range dbStructure.Drafts {
+		if draft.OwnerId == userId {
+			delete(dbStructure.Drafts, id)
+		}
+	}
+
+	// A conversation is never kept with a single participant
+	for id, conversation := range dbStructure.Conversations {
+		if _, ok := conversation.participant(userId); ok {
+			delete(dbStructure.Conversations, id)
+		}
+	}
+	for id, message := range dbStructure.Messages {
+		if _, exists := dbStructure.Conversations[message.ConversationId]; !exists {
+			delete(dbStructure.Messages, id)
+		}
+	}
+
+	// Expiring them right away lets the janitor remove their archives
+	for id, export := range dbStructure.Exports {
+		if export.UserId == userId {
+			export.ExpiresAt = time.Time{}
+			dbStructure.Exports[id] = export
+		}
+	}
+
+	delete(dbStructure.Users, userId)
+	log.Printf("Purged user with id %d", userId)
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+// Relation A block or a mute of TargetId by UserId
+type Relation struct {
+	UserId    int       `json:"user_id"`
+	TargetId  int       `json:"target_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var ErrSelfRelation = errors.New("users can not block or mute themselves")
+var ErrBlocked = errors.New("one of the users blocked the other")
+
+// blocked Blocks work both ways, neither user sees or reaches the other
+func (dbStructure *DBStructure) blocked(a, b int) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	_, aBlockedB := dbStructure.Blocks[followKey(a, b)]
+	_, bBlockedA := dbStructure.Blocks[followKey(b, a)]
+	return aBlockedB || bBlockedA
+}
+
+// muted Mutes only hide the target from the listings of the user who muted them
+func (dbStructure *DBStructure) muted(userId, targetId int) bool {
+	_, exists := dbStructure.Mutes[followKey(userId, targetId)]
+	return exists
+}
+
+// BlockUser Also removes the follows between both users, blocking someone twice is not an error
+func (db *DB) BlockUser(userId, targetId int, now time.Time) error {
+	return db.addRelation(userId, targetId, now, func(dbStructure *DBStructure) map[string]Relation {
+		delete(dbStructure.Follows, followKey(userId, targetId))
+		delete(dbStructure.Follows, followKey(targetId, userId))
+		return dbStructure.Blocks
+	})
+}
+
+func (db *DB) UnblockUser(userId, targetId int) error {
+	return db.removeRelation(userId, targetId, func(dbStructure *DBStructure) map[string]Relation {
+		return dbStructure.Blocks
+	})
+}
+
+// MuteUser Muting someone twice is not an error
+func (db *DB) MuteUser(userId, targetId int, now time.Time) error {
+	return db.addRelation(userId, targetId, now, func(dbStructure *DBStructure) map[string]Relation {
+		return dbStructure.Mutes
+	})
+}
+
+func (db *DB) UnmuteUser(userId, targetId int) error {
+	return db.removeRelation(userId, targetId, func(dbStructure *DBStructure) map[string]Relation {
+		return dbStructure.Mutes
+	})
+}
+
+// BlockedUsers The users blocked by userId, most recent first
+func (db *DB) BlockedUsers(userId int) ([]Relation, error) {
+	return db.relations(userId, func(dbStructure *DBStructure) map[string]Relation {
+		return dbStructure.Blocks
+	})
+}
+
+// MutedUsers The users muted by userId, most recent first
+func (db *DB) MutedUsers(userId int) ([]Relation, error) {
+	return db.relations(userId, func(dbStructure *DBStructure) map[string]Relation {
+		return dbStructure.Mutes
+	})
+}
+
+func (db *DB) addRelation(userId, targetId int, now time.Time, relations func(*DBStructure) map[string]Relation) error {
+
+	if userId == targetId {
+		return ErrSelfRelation
+	}
+
+	return db.update(func(dbStructure *DBStructure) error {
+		for _, id := range []int{userId, targetId} {
+			if user, exists := dbStructure.Users[id]; !exists || user.Deleted() {
+				return UserNotExists
+			}
+		}
+
+		byKey := relations(dbStructure)
+		key := followKey(userId, targetId)
+		if _, exists := byKey[key]; exists {
+			return errNoChange
+		}
+		byKey[key] = Relation{
+			UserId:    userId,
+			TargetId:  targetId,
+			CreatedAt: now,
+		}
+		return nil
+	})
+}
+
+func (db *DB) removeRelation(userId, targetId int, relations func(*DBStructure) map[string]Relation) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		byKey := relations(dbStructure)
+		key := followKey(userId, targetId)
+		if _, exists := byKey[key]; !exists {
+			return errNoChange
+		}
+		delete(byKey, key)
+		return nil
+	})
+}
+
+func (db *DB) relations(userId int, relations func(*DBStructure) map[string]Relation) ([]Relation, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list relations: %q", err)
+		return nil, err
+	}
+
+	return dbStructure.relationsOf(userId, relations(&dbStructure)), nil
+}
+
+func (dbStructure *DBStructure) relationsOf(userId int, byKey map[string]Relation) []Relation {
+	list := make([]Relation, 0)
+	for _, relation := range byKey {
+		if relation.UserId == userId {
+			list = append(list, relation)
+		}
+	}
+	slices.SortFunc(list, func(a, b Relation) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.TargetId, b.TargetId))
+	})
+	return list
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// Bookmark A chirp saved by a user, only that user can see it
+type Bookmark struct {
+	UserId    int       `json:"user_id"`
+	ChirpId   int       `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BookmarkedChirp A bookmark together with the chirp as its owner sees it now
+type BookmarkedChirp struct {
+	Chirp        Chirp     `json:"chirp"`
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// BookmarkCursor Position of a bookmark in the listing, bookmarks made at the same instant are ordered by chirp
+type BookmarkCursor struct {
+	CreatedAt time.Time
+	ChirpId   int
+}
+
+var ErrInvalidPin = errors.New("only your own published chirps can be pinned")
+
+func bookmarkKey(userId, chirpId int) string {
+	return fmt.Sprintf("%d:%d", userId, chirpId)
+}
+
+func (c BookmarkCursor) String() string {
+	return fmt.Sprintf("%d-%d", c.CreatedAt.UnixNano(), c.ChirpId)
+}
+
+func ParseBookmarkCursor(s string) (BookmarkCursor, error) {
+	var nanos int64
+	var chirpId int
+	if _, err := fmt.Sscanf(s, "%d-%d", &nanos, &chirpId); err != nil {
+		return BookmarkCursor{}, errors.New("invalid bookmark cursor")
+	}
+	return BookmarkCursor{CreatedAt: time.Unix(0, nanos).UTC(), ChirpId: chirpId}, nil
+}
+
+// compareBookmarks Most recent first
+func compareBookmarks(a, b BookmarkCursor) int {
+	return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ChirpId, a.ChirpId))
+}
+
+// pinnable Only published chirps of the user can be pinned
+func (dbStructure *DBStructure) pinnable(userId, chirpId int) bool {
+	chirp, exists := dbStructure.Chirps[chirpId]
+	return exists && !chirp.Scheduled() && chirp.AuthorId == userId
+}
+
+// removeChirp Deletes the chirp along with the bookmarks and the pin pointing at it
+func (dbStructure *DBStructure) removeChirp(chirpId int) {
+	chirp := dbStructure.Chirps[chirpId]
+	delete(dbStructure.Chirps, chirpId)
+
+	for key, bookmark := range dbStructure.Bookmarks {
+		if bookmark.ChirpId == chirpId {
+			delete(dbStructure.Bookmarks, key)
+		}
+	}
+
+	if author, exists := dbStructure.Users[chirp.AuthorId]; exists && author.PinnedChirpId == chirpId {
+		author.PinnedChirpId = 0
+		dbStructure.Users[author.Id] = author
+	}
+}
+
+// BookmarkChirp Only chirps the user can read can be bookmarked, bookmarking a chirp twice is not an error
+func (db *DB) BookmarkChirp(userId, chirpId int, now time.Time) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		chirp, exists := dbStructure.Chirps[chirpId]
+		if !exists || dbStructure.authorDeleted(chirp) || chirp.Scheduled() || !dbStructure.canView(chirp, userId) {
+			return ChirpNotExists
+		}
+
+		key := bookmarkKey(userId, chirpId)
+		if _, exists := dbStructure.Bookmarks[key]; exists {
+			return errNoChange
+		}
+		dbStructure.Bookmarks[key] = Bookmark{
+			UserId:    userId,
+			ChirpId:   chirpId,
+			CreatedAt: now,
+		}
+		return nil
+	})
+}
+
+// UnbookmarkChirp Removing a bookmark that does not exist is not an error
+func (db *DB) UnbookmarkChirp(userId, chirpId int) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		key := bookmarkKey(userId, chirpId)
+		if _, exists := dbStructure.Bookmarks[key]; !exists {
+			return errNoChange
+		}
+		delete(dbStructure.Bookmarks, key)
+		return nil
+	})
+}
+
+// Bookmarks A page of at most limit bookmarks after the cursor, most recent first. A nil cursor starts from the latest one.
+// Bookmarks of chirps the user can no longer read are skipped but kept, they come back if the chirp does.
+func (db *DB) Bookmarks(userId int, after *BookmarkCursor, limit int) ([]BookmarkedChirp, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list bookmarks: %q", err)
+		return nil, err
+	}
+
+	bookmarks := make([]Bookmark, 0)
+	for _, bookmark := range dbStructure.Bookmarks {
+		if bookmark.UserId != userId {
+			continue
+		}
+		if after != nil && compareBookmarks(*after, BookmarkCursor{bookmark.CreatedAt, bookmark.ChirpId}) >= 0 {
+			continue
+		}
+		chirp := dbStructure.Chirps[bookmark.ChirpId]
+		if dbStructure.authorDeleted(chirp) || !dbStructure.canView(chirp, userId) {
+			continue
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	slices.SortFunc(bookmarks, func(a, b Bookmark) int {
+		return compareBookmarks(BookmarkCursor{a.CreatedAt, a.ChirpId}, BookmarkCursor{b.CreatedAt, b.ChirpId})
+	})
+	if len(bookmarks) > limit {
+		bookmarks = bookmarks[:limit]
+	}
+
+	page := make([]BookmarkedChirp, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		page = append(page, BookmarkedChirp{
+			Chirp:        dbStructure.presentChirp(dbStructure.Chirps[bookmark.ChirpId]),
+			BookmarkedAt: bookmark.CreatedAt,
+		})
+	}
+
+	return page, nil
+}
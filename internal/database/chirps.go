@@ -5,27 +5,67 @@ import (
 	"fmt"
 	"github.com/benjamin-vq/chirpy/internal/assert"
 	"log"
+	"slices"
+	"time"
 )
 
 type Chirp struct {
-	Body     string `json:"body"`
-	Id       int    `json:"id"`
-	AuthorId int    `json:"author_id"`
+	Body       string     `json:"body"`
+	Id         int        `json:"id"`
+	AuthorId   int        `json:"author_id"`
+	Visibility Visibility `json:"visibility"`
+
+	MediaIds []string   `json:"media_ids,omitempty"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	Entities []Entity   `json:"entities,omitempty"`
+	// PublishAt Set while the chirp is scheduled, only its author can see it until then
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Previews Filled in when reading chirps, never stored with them
+	Previews []LinkPreview `json:"previews,omitempty"`
 }
 
 var ChirpNotExists = errors.New("chirp does not exist")
 var IncorrectAuthorId = errors.New("user id does not match chirp author id")
 
-func (db *DB) CreateChirp(body string, authorId int) (Chirp, error) {
+func (c Chirp) Scheduled() bool {
+	return c.PublishAt != nil
+}
 
-	//assert.That(body != "", "Chirp body can not be empty")
-	assert.That(authorId != 0, "Should provide a valid author id")
+// CreateChirp Assigns the id of the new chirp. Attached media has to be uploaded by the author,
+// previews of the linked urls get queued.
+func (db *DB) CreateChirp(chirp Chirp) (Chirp, error) {
 
-	dbStructure, err := db.loadDB()
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		chirp, err = dbStructure.insertChirp(chirp)
+		return err
+	})
 	if err != nil {
 		return Chirp{}, err
 	}
 
+	return chirp, nil
+}
+
+func (dbStructure *DBStructure) insertChirp(chirp Chirp) (Chirp, error) {
+
+	//assert.That(body != "", "Chirp body can not be empty")
+	authorId, mediaIds := chirp.AuthorId, chirp.MediaIds
+	assert.That(authorId != 0, "Should provide a valid author id")
+
+	if author, exists := dbStructure.Users[authorId]; !exists || author.Deleted() {
+		log.Printf("Chirp author with id %d does not exist or was deleted", authorId)
+		return Chirp{}, UserNotExists
+	}
+
+	for i, mediaId := range mediaIds {
+		media, exists := dbStructure.Media[mediaId]
+		if !exists || media.OwnerId != authorId || slices.Contains(mediaIds[:i], mediaId) {
+			log.Printf("User with id %d can not attach media %q", authorId, mediaId)
+			return Chirp{}, ErrInvalidMedia
+		}
+	}
+
 	// Ugly, but works. A better alternative would be to use another data structure for chirps
 	var chirpId int
 	for k, _ := range dbStructure.Chirps {
@@ -35,24 +75,22 @@ func (db *DB) CreateChirp(body string, authorId int) (Chirp, error) {
 	}
 	// We just assigned to the latest id, increment.
 	chirpId += 1
-	chirp := Chirp{
-		Body:     body,
-		Id:       chirpId,
-		AuthorId: authorId,
+	chirp.Id = chirpId
+	chirp.Previews = nil
+	chirp.Entities = dbStructure.resolveMentions(chirp.Entities, chirp.AuthorId)
+	if chirp.Visibility == "" {
+		chirp.Visibility = VisibilityPublic
 	}
 	assert.That(dbStructure.Chirps != nil, "Chirps map should be initialized")
 	dbStructure.Chirps[chirpId] = chirp
+	dbStructure.queuePreviews(chirp.Entities, time.Now().UTC())
 
-	err = db.writeDB(dbStructure)
-	if err != nil {
-		log.Printf("Error writing database structure: %q", err)
-		return Chirp{}, err
-	}
-
-	return chirp, nil
+	return dbStructure.presentChirp(chirp), nil
 }
 
-func (db *DB) GetChirps() ([]Chirp, error) {
+// GetChirps Only the chirps the viewer is allowed to see, without the ones of users they muted.
+// viewerId is zero for anonymous readers.
+func (db *DB) GetChirps(viewerId int) ([]Chirp, error) {
 
 	dbStructure, err := db.loadDB()
 	if err != nil {
@@ -62,13 +100,17 @@ func (db *DB) GetChirps() ([]Chirp, error) {
 
 	chirps := make([]Chirp, 0, len(dbStructure.Chirps))
 	for _, v := range dbStructure.Chirps {
-		chirps = append(chirps, v)
+		if dbStructure.authorDeleted(v) || v.Scheduled() || !dbStructure.canView(v, viewerId) || dbStructure.muted(viewerId, v.AuthorId) {
+			continue
+		}
+		chirps = append(chirps, dbStructure.presentChirp(v))
 	}
 
 	return chirps, nil
 }
 
-func (db *DB) ChirpById(id int) (Chirp, error) {
+// ChirpById Chirps the viewer is not allowed to see do not exist for them
+func (db *DB) ChirpById(id, viewerId int) (Chirp, error) {
 
 	dbStructure, err := db.loadDB()
 	if err != nil {
@@ -78,40 +120,73 @@ func (db *DB) ChirpById(id int) (Chirp, error) {
 
 	chirp, exists := dbStructure.Chirps[id]
 
-	if !exists {
+	if !exists || dbStructure.authorDeleted(chirp) || chirp.Scheduled() || !dbStructure.canView(chirp, viewerId) {
 		log.Printf("Chirp with id %d does not exist in database", id)
 		return Chirp{}, fmt.Errorf("chirp with id %d does not exist", id)
 	}
 
-	return chirp, nil
+	return dbStructure.presentChirp(chirp), nil
 }
 
-func (db *DB) DeleteChirpById(chirpId, userId int) error {
-	dbStructure, err := db.loadDB()
+// UpdateChirpBody Only the author can edit a chirp
+func (db *DB) UpdateChirpBody(chirpId, userId int, body string, entities []Entity, now time.Time) (Chirp, error) {
+
+	chirp := Chirp{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		chirp, exists = dbStructure.Chirps[chirpId]
+		if !exists || dbStructure.authorDeleted(chirp) || chirp.Scheduled() {
+			return ChirpNotExists
+		}
+
+		if chirp.AuthorId != userId {
+			log.Printf("Chirp author id (%d) does not match user id (%d)", chirp.AuthorId, userId)
+			return IncorrectAuthorId
+		}
+
+		chirp.Body = body
+		chirp.Entities = dbStructure.resolveMentions(entities, chirp.AuthorId)
+		chirp.EditedAt = &now
+		dbStructure.Chirps[chirpId] = chirp
+		dbStructure.queuePreviews(entities, now)
+		chirp = dbStructure.presentChirp(chirp)
+		return nil
+	})
 	if err != nil {
-		log.Printf("Could not load database file to delete chirp: %q", err)
-		return err
+		return Chirp{}, err
 	}
 
-	chirp, exists := dbStructure.Chirps[chirpId]
-	if !exists {
-		log.Printf("Could not delete chirp with id %d because it does not exist", chirpId)
-		return ChirpNotExists
-	}
+	return chirp, nil
+}
 
-	if chirp.AuthorId != userId {
-		log.Printf("Chirp author id (%d) does not match user id (%d)", chirp.AuthorId, userId)
-		return IncorrectAuthorId
-	}
+func (db *DB) DeleteChirpById(chirpId, userId int) error {
 
-	delete(dbStructure.Chirps, chirpId)
-	log.Printf("Deleted chirp with author id %d from database", userId)
+	err := db.update(func(dbStructure *DBStructure) error {
+		// Scheduled chirps are cancelled instead, they were never published
+		chirp, exists := dbStructure.Chirps[chirpId]
+		if !exists || chirp.Scheduled() {
+			log.Printf("Could not delete chirp with id %d because it does not exist", chirpId)
+			return ChirpNotExists
+		}
 
-	err = db.writeDB(dbStructure)
+		if chirp.AuthorId != userId {
+			log.Printf("Chirp author id (%d) does not match user id (%d)", chirp.AuthorId, userId)
+			return IncorrectAuthorId
+		}
+
+		dbStructure.removeChirp(chirpId)
+		return nil
+	})
 	if err != nil {
-		log.Printf("Could not write database file to delete chirp: %q", err)
 		return err
 	}
 
+	log.Printf("Deleted chirp with author id %d from database", userId)
 	return nil
 }
+
+// authorDeleted Chirps of users waiting to be purged are hidden, anonymized chirps have no author
+func (dbStructure *DBStructure) authorDeleted(chirp Chirp) bool {
+	author, exists := dbStructure.Users[chirp.AuthorId]
+	return exists && author.Deleted()
+}
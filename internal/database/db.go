@@ -16,9 +16,82 @@ type DB struct {
 }
 
 type DBStructure struct {
-	Chirps        map[int]Chirp           `json:"chirps"`
-	Users         map[int]User            `json:"users"`
-	RefreshTokens map[string]RefreshToken `json:"refresh_tokens"`
+	Chirps              map[int]Chirp                 `json:"chirps"`
+	Users               map[int]User                  `json:"users"`
+	RefreshTokens       map[string]RefreshToken       `json:"refresh_tokens"`
+	PasswordResetTokens map[string]PasswordResetToken `json:"password_reset_tokens"`
+	LoginAttempts       map[string]LoginAttempt       `json:"login_attempts"`
+	Exports             map[string]Export             `json:"exports"`
+	Follows             map[string]Follow             `json:"follows"`
+	Media               map[string]Media              `json:"media"`
+	WebhookInbox        map[string]InboxEvent         `json:"webhook_inbox"`
+	WebhookEndpoints    map[string]WebhookEndpoint    `json:"webhook_endpoints"`
+	WebhookDeliveries   map[string]WebhookDelivery    `json:"webhook_deliveries"`
+	LinkPreviews        map[string]LinkPreview        `json:"link_previews"`
+	Drafts              map[string]Draft              `json:"drafts"`
+	Blocks              map[string]Relation           `json:"blocks"`
+	Mutes               map[string]Relation           `json:"mutes"`
+	Conversations       map[string]Conversation       `json:"conversations"`
+	Messages            map[string]Message            `json:"messages"`
+	Bookmarks           map[string]Bookmark           `json:"bookmarks"`
+}
+
+// initialize Makes sure every map exists, database files written by older versions may be missing some.
+func (dbStructure *DBStructure) initialize() {
+	if dbStructure.Chirps == nil {
+		dbStructure.Chirps = make(map[int]Chirp)
+	}
+	if dbStructure.Users == nil {
+		dbStructure.Users = make(map[int]User)
+	}
+	if dbStructure.RefreshTokens == nil {
+		dbStructure.RefreshTokens = make(map[string]RefreshToken)
+	}
+	if dbStructure.PasswordResetTokens == nil {
+		dbStructure.PasswordResetTokens = make(map[string]PasswordResetToken)
+	}
+	if dbStructure.LoginAttempts == nil {
+		dbStructure.LoginAttempts = make(map[string]LoginAttempt)
+	}
+	if dbStructure.Exports == nil {
+		dbStructure.Exports = make(map[string]Export)
+	}
+	if dbStructure.Follows == nil {
+		dbStructure.Follows = make(map[string]Follow)
+	}
+	if dbStructure.Media == nil {
+		dbStructure.Media = make(map[string]Media)
+	}
+	if dbStructure.WebhookInbox == nil {
+		dbStructure.WebhookInbox = make(map[string]InboxEvent)
+	}
+	if dbStructure.WebhookEndpoints == nil {
+		dbStructure.WebhookEndpoints = make(map[string]WebhookEndpoint)
+	}
+	if dbStructure.WebhookDeliveries == nil {
+		dbStructure.WebhookDeliveries = make(map[string]WebhookDelivery)
+	}
+	if dbStructure.LinkPreviews == nil {
+		dbStructure.LinkPreviews = make(map[string]LinkPreview)
+	}
+	if dbStructure.Drafts == nil {
+		dbStructure.Drafts = make(map[string]Draft)
+	}
+	if dbStructure.Blocks == nil {
+		dbStructure.Blocks = make(map[string]Relation)
+	}
+	if dbStructure.Mutes == nil {
+		dbStructure.Mutes = make(map[string]Relation)
+	}
+	if dbStructure.Conversations == nil {
+		dbStructure.Conversations = make(map[string]Conversation)
+	}
+	if dbStructure.Messages == nil {
+		dbStructure.Messages = make(map[string]Message)
+	}
+	if dbStructure.Bookmarks == nil {
+		dbStructure.Bookmarks = make(map[string]Bookmark)
+	}
 }
 
 func NewDB(path string) (*DB, error) {
@@ -36,16 +109,16 @@ func NewDB(path string) (*DB, error) {
 }
 
 func (db *DB) ensureDB() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	_, err := os.ReadFile(db.path)
 
 	if errors.Is(err, os.ErrNotExist) {
 		log.Printf("Database file does not exist, ensuring it exists by creating it")
-		dbStructure := DBStructure{
-			Chirps:        make(map[int]Chirp),
-			Users:         make(map[int]User),
-			RefreshTokens: make(map[string]RefreshToken),
-		}
-		err := db.writeDB(dbStructure)
+		dbStructure := DBStructure{}
+		dbStructure.initialize()
+		err := db.writeFile(dbStructure)
 		assert.NoError(err, "Database could not be initialized: %q", err)
 		return nil
 	}
@@ -53,10 +126,42 @@ func (db *DB) ensureDB() error {
 	return err
 }
 
+// loadDB A snapshot for reading, changes made to it are never written. Use update to change the database.
 func (db *DB) loadDB() (DBStructure, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	return db.readFile()
+}
+
+// errNoChange Ends an update early without writing the database
+var errNoChange = errors.New("nothing to write")
+
+// update Holds the write lock from loading the database until the changes of fn are written, so concurrent
+// writers never overwrite each other. Nothing is written when fn returns an error, fn must not call other
+// methods of db.
+func (db *DB) update(fn func(dbStructure *DBStructure) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	dbStructure, err := db.readFile()
+	if err != nil {
+		return err
+	}
+
+	err = fn(&dbStructure)
+	if errors.Is(err, errNoChange) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.writeFile(dbStructure)
+}
+
+func (db *DB) readFile() (DBStructure, error) {
+
 	data, err := os.ReadFile(db.path)
 
 	if err != nil {
@@ -72,12 +177,11 @@ func (db *DB) loadDB() (DBStructure, error) {
 		return DBStructure{}, err
 	}
 
+	dbStructure.initialize()
 	return dbStructure, nil
 }
 
-func (db *DB) writeDB(dbStructure DBStructure) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+func (db *DB) writeFile(dbStructure DBStructure) error {
 
 	data, err := json.Marshal(&dbStructure)
 
@@ -94,5 +198,4 @@ func (db *DB) writeDB(dbStructure DBStructure) error {
 
 	log.Print("Successfully wrote database structure to file")
 	return nil
-
 }
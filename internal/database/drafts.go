@@ -0,0 +1,162 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+// Draft An unpublished chirp shared between the devices of its owner. Version goes up with every
+// change so a device can tell when it is editing a stale copy.
+type Draft struct {
+	Id        string    `json:"id"`
+	OwnerId   int       `json:"owner_id"`
+	Body      string    `json:"body"`
+	MediaIds  []string  `json:"media_ids,omitempty"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var DraftNotExists = errors.New("draft does not exist")
+var ErrDraftConflict = errors.New("draft was changed on another device")
+var ErrTooManyDrafts = errors.New("too many drafts")
+
+// CreateDraft Fails with ErrTooManyDrafts when the owner already has maxDrafts, there is no limit when it is zero
+func (db *DB) CreateDraft(draft Draft, maxDrafts int) (Draft, error) {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		if owner, exists := dbStructure.Users[draft.OwnerId]; !exists || owner.Deleted() {
+			return UserNotExists
+		}
+
+		if maxDrafts > 0 {
+			count := 0
+			for _, existing := range dbStructure.Drafts {
+				if existing.OwnerId == draft.OwnerId {
+					count++
+				}
+			}
+			if count >= maxDrafts {
+				return ErrTooManyDrafts
+			}
+		}
+
+		draft.Version = 1
+		draft.UpdatedAt = draft.CreatedAt
+		dbStructure.Drafts[draft.Id] = draft
+		return nil
+	})
+	if err != nil {
+		return Draft{}, err
+	}
+
+	return draft, nil
+}
+
+// DraftsByOwner Most recently updated first
+func (db *DB) DraftsByOwner(ownerId int) ([]Draft, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list drafts: %q", err)
+		return nil, err
+	}
+
+	drafts := make([]Draft, 0)
+	for _, draft := range dbStructure.Drafts {
+		if draft.OwnerId == ownerId {
+			drafts = append(drafts, draft)
+		}
+	}
+	slices.SortFunc(drafts, func(a, b Draft) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+
+	return drafts, nil
+}
+
+// DraftById Drafts of other users do not exist for the caller
+func (db *DB) DraftById(id string, ownerId int) (Draft, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to find draft: %q", err)
+		return Draft{}, err
+	}
+
+	draft, exists := dbStructure.Drafts[id]
+	if !exists || draft.OwnerId != ownerId {
+		return Draft{}, DraftNotExists
+	}
+
+	return draft, nil
+}
+
+// UpdateDraft Replaces the content of the draft. The version has to match the stored one,
+// otherwise ErrDraftConflict is returned with the stored draft and nothing changes.
+func (db *DB) UpdateDraft(id string, ownerId int, body string, mediaIds []string, version int, now time.Time) (Draft, error) {
+
+	draft := Draft{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		draft, exists = dbStructure.Drafts[id]
+		if !exists || draft.OwnerId != ownerId {
+			return DraftNotExists
+		}
+		if version != draft.Version {
+			log.Printf("Draft %s is at version %d but the update was based on version %d", id, draft.Version, version)
+			return ErrDraftConflict
+		}
+
+		draft.Body = body
+		draft.MediaIds = mediaIds
+		draft.Version++
+		draft.UpdatedAt = now
+		dbStructure.Drafts[id] = draft
+		return nil
+	})
+	if errors.Is(err, ErrDraftConflict) {
+		return draft, err
+	}
+	if err != nil {
+		return Draft{}, err
+	}
+
+	return draft, nil
+}
+
+func (db *DB) DeleteDraft(id string, ownerId int) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		draft, exists := dbStructure.Drafts[id]
+		if !exists || draft.OwnerId != ownerId {
+			return DraftNotExists
+		}
+		delete(dbStructure.Drafts, id)
+		return nil
+	})
+}
+
+// PublishDraft Creates the chirp and removes the draft in the same write, so a draft is never published twice
+func (db *DB) PublishDraft(id string, chirp Chirp) (Chirp, error) {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		draft, exists := dbStructure.Drafts[id]
+		if !exists || draft.OwnerId != chirp.AuthorId {
+			return DraftNotExists
+		}
+
+		var err error
+		chirp, err = dbStructure.insertChirp(chirp)
+		if err != nil {
+			return err
+		}
+		delete(dbStructure.Drafts, id)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending"
+	ExportReady   ExportStatus = "ready"
+	ExportFailed  ExportStatus = "failed"
+)
+
+type Export struct {
+	Id        string       `json:"id"`
+	UserId    int          `json:"user_id"`
+	Status    ExportStatus `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Path      string       `json:"path,omitempty"`
+}
+
+// UserData Everything stored about a user, secrets like password hashes are left out
+type UserData struct {
+	Profile   User              `json:"profile"`
+	Chirps    []Chirp           `json:"chirps"`
+	Sessions  []Session         `json:"sessions"`
+	Exports   []Export          `json:"exports"`
+	Following []Follow          `json:"following"`
+	Media     []Media           `json:"media"`
+	Webhooks  []WebhookEndpoint `json:"webhooks"`
+	Drafts    []Draft           `json:"drafts"`
+	Blocks    []Relation        `json:"blocks"`
+	Mutes     []Relation        `json:"mutes"`
+	// Conversations Messages of both participants are included, they belong to the conversation
+	Conversations []Conversation `json:"conversations"`
+	Messages      []Message      `json:"messages"`
+	Bookmarks     []Bookmark     `json:"bookmarks"`
+}
+
+// Session A refresh token without the token itself
+type Session struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var ExportNotExists = errors.New("export does not exist")
+var ErrExportPending = errors.New("an export is already being prepared")
+
+// CreateExport Users can only have one pending export at a time, ErrExportPending is returned otherwise
+func (db *DB) CreateExport(id string, userId int, now, expiresAt time.Time) (Export, error) {
+
+	export := Export{
+		Id:        id,
+		UserId:    userId,
+		Status:    ExportPending,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	err := db.update(func(dbStructure *DBStructure) error {
+		if user, exists := dbStructure.Users[userId]; !exists || user.Deleted() {
+			return UserNotExists
+		}
+		for _, other := range dbStructure.Exports {
+			if other.UserId == userId && other.Status == ExportPending {
+				return ErrExportPending
+			}
+		}
+
+		dbStructure.Exports[id] = export
+		return nil
+	})
+	if err != nil {
+		return Export{}, err
+	}
+
+	return export, nil
+}
+
+// FinishExport Marks the export as ready when path is set, as failed otherwise
+func (db *DB) FinishExport(id, path string) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		export, exists := dbStructure.Exports[id]
+		if !exists {
+			return ExportNotExists
+		}
+
+		export.Status = ExportFailed
+		if path != "" {
+			export.Status = ExportReady
+			export.Path = path
+		}
+		dbStructure.Exports[id] = export
+		return nil
+	})
+}
+
+// PendingExports Exports waiting to be built, oldest first
+func (db *DB) PendingExports() ([]Export, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to retrieve pending exports: %q", err)
+		return nil, err
+	}
+
+	pending := make([]Export, 0)
+	for _, export := range dbStructure.Exports {
+		if export.Status == ExportPending {
+			pending = append(pending, export)
+		}
+	}
+	slices.SortFunc(pending, func(a, b Export) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.Id, b.Id))
+	})
+
+	return pending, nil
+}
+
+func (db *DB) ExportById(id string) (Export, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to retrieve export: %q", err)
+		return Export{}, err
+	}
+
+	export, exists := dbStructure.Exports[id]
+	if !exists {
+		return Export{}, ExportNotExists
+	}
+
+	return export, nil
+}
+
+func (db *DB) UserData(userId int) (UserData, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to export user data: %q", err)
+		return UserData{}, err
+	}
+
+	user, exists := dbStructure.Users[userId]
+	if !exists {
+		return UserData{}, UserNotExists
+	}
+
+	user.HashedPassword = ""
+	user.TOTPSecret = ""
+	user.PendingTOTPSecret = ""
+	user.RecoveryCodes = nil
+
+	data := UserData{
+		Profile:   user,
+		Chirps:    make([]Chirp, 0),
+		Sessions:  make([]Session, 0),
+		Exports:   make([]Export, 0),
+		Following: make([]Follow, 0),
+		Media:     make([]Media, 0),
+		Webhooks:  make([]WebhookEndpoint, 0),
+		Drafts:    make([]Draft, 0),
+
+		Conversations: make([]Conversation, 0),
+		Messages:      make([]Message, 0),
+		Bookmarks:     make([]Bookmark, 0),
+	}
+
+	for _, chirp := range dbStructure.Chirps {
+		if chirp.AuthorId == userId {
+			data.Chirps = append(data.Chirps, chirp)
+		}
+	}
+	slices.SortFunc(data.Chirps, func(a, b Chirp) int { return a.Id - b.Id })
+
+	for _, refreshToken := range dbStructure.RefreshTokens {
+		if refreshToken.UserId == userId {
+			data.Sessions = append(data.Sessions, Session{ExpiresAt: refreshToken.ExpiresAt})
+		}
+	}
+
+	for _, export := range dbStructure.Exports {
+		if export.UserId == userId {
+			export.Path = ""
+			data.Exports = append(data.Exports, export)
+		}
+	}
+
+	for _, follow := range dbStructure.Follows {
+		if follow.FollowerId == userId {
+			data.Following = append(data.Following, follow)
+		}
+	}
+	slices.SortFunc(data.Following, func(a, b Follow) int { return a.FolloweeId - b.FolloweeId })
+
+	for _, media := range dbStructure.Media {
+		if media.OwnerId == userId {
+			data.Media = append(data.Media, media)
+		}
+	}
+	slices.SortFunc(data.Media, func(a, b Media) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, endpoint := range dbStructure.WebhookEndpoints {
+		if endpoint.OwnerId == userId {
+			endpoint.Secret = ""
+			data.Webhooks = append(data.Webhooks, endpoint)
+		}
+	}
+	slices.SortFunc(data.Webhooks, func(a, b WebhookEndpoint) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, draft := range dbStructure.Drafts {
+		if draft.OwnerId == userId {
+			data.Drafts = append(data.Drafts, draft)
+		}
+	}
+	slices.SortFunc(data.Drafts, func(a, b Draft) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	data.Blocks = dbStructure.relationsOf(userId, dbStructure.Blocks)
+	data.Mutes = dbStructure.relationsOf(userId, dbStructure.Mutes)
+
+	for _, conversation := range dbStructure.Conversations {
+		if _, ok := conversation.participant(userId); ok {
+			data.Conversations = append(data.Conversations, conversation)
+		}
+	}
+	slices.SortFunc(data.Conversations, func(a, b Conversation) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, message := range dbStructure.Messages {
+		if conversation, exists := dbStructure.Conversations[message.ConversationId]; exists {
+			if _, ok := conversation.participant(userId); ok {
+				data.Messages = append(data.Messages, message)
+			}
+		}
+	}
+	slices.SortFunc(data.Messages, func(a, b Message) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.Seq, b.Seq))
+	})
+
+	for _, bookmark := range dbStructure.Bookmarks {
+		if bookmark.UserId == userId {
+			data.Bookmarks = append(data.Bookmarks, bookmark)
+		}
+	}
+	slices.SortFunc(data.Bookmarks, func(a, b Bookmark) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ChirpId, b.ChirpId))
+	})
+
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+type Follow struct {
+	FollowerId int       `json:"follower_id"`
+	FolloweeId int       `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ProfileStats Counts shown on public profiles, soft deleted users and chirps hidden from anonymous readers are not counted
+type ProfileStats struct {
+	Chirps    int `json:"chirps"`
+	Followers int `json:"followers"`
+	Following int `json:"following"`
+}
+
+var ErrSelfFollow = errors.New("users can not follow themselves")
+
+func followKey(followerId, followeeId int) string {
+	return fmt.Sprintf("%d:%d", followerId, followeeId)
+}
+
+// FollowUser Following someone twice is not an error
+func (db *DB) FollowUser(followerId, followeeId int, now time.Time) error {
+
+	if followerId == followeeId {
+		return ErrSelfFollow
+	}
+
+	followed := false
+	err := db.update(func(dbStructure *DBStructure) error {
+		for _, id := range []int{followerId, followeeId} {
+			if user, exists := dbStructure.Users[id]; !exists || user.Deleted() {
+				return UserNotExists
+			}
+		}
+
+		if dbStructure.blocked(followerId, followeeId) {
+			return ErrBlocked
+		}
+
+		key := followKey(followerId, followeeId)
+		if _, exists := dbStructure.Follows[key]; exists {
+			return errNoChange
+		}
+
+		dbStructure.Follows[key] = Follow{
+			FollowerId: followerId,
+			FolloweeId: followeeId,
+			CreatedAt:  now,
+		}
+		followed = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if followed {
+		log.Printf("User with id %d followed user with id %d", followerId, followeeId)
+	}
+	return nil
+}
+
+// UnfollowUser Unfollowing someone not followed is not an error
+func (db *DB) UnfollowUser(followerId, followeeId int) error {
+
+	unfollowed := false
+	err := db.update(func(dbStructure *DBStructure) error {
+		key := followKey(followerId, followeeId)
+		if _, exists := dbStructure.Follows[key]; !exists {
+			return errNoChange
+		}
+		delete(dbStructure.Follows, key)
+		unfollowed = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if unfollowed {
+		log.Printf("User with id %d unfollowed user with id %d", followerId, followeeId)
+	}
+	return nil
+}
+
+func (db *DB) ProfileStats(userId int) (ProfileStats, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to count profile stats: %q", err)
+		return ProfileStats{}, err
+	}
+
+	stats := ProfileStats{}
+	for _, chirp := range dbStructure.Chirps {
+		if chirp.AuthorId == userId && !chirp.Scheduled() && dbStructure.canView(chirp, 0) {
+			stats.Chirps++
+		}
+	}
+
+	for _, follow := range dbStructure.Follows {
+		switch userId {
+		case follow.FolloweeId:
+			if !dbStructure.Users[follow.FollowerId].Deleted() {
+				stats.Followers++
+			}
+		case follow.FollowerId:
+			if !dbStructure.Users[follow.FolloweeId].Deleted() {
+				stats.Following++
+			}
+		}
+	}
+
+	return stats, nil
+}
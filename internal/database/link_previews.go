@@ -0,0 +1,112 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+const EntityURL = "url"
+
+// Entity A part of the chirp body, Start and End are offsets in unicode code points with End exclusive
+type Entity struct {
+	Type   string `json:"type"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	URL    string `json:"url,omitempty"`
+	Handle string `json:"handle,omitempty"`
+	UserId int    `json:"user_id,omitempty"`
+}
+
+type PreviewStatus string
+
+const (
+	PreviewPending PreviewStatus = "pending"
+	PreviewReady   PreviewStatus = "ready"
+	PreviewFailed  PreviewStatus = "failed"
+)
+
+// LinkPreview Cached OpenGraph data of a url, shared by every chirp that links to it
+type LinkPreview struct {
+	URL         string        `json:"url"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	ImageURL    string        `json:"image_url,omitempty"`
+	Status      PreviewStatus `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	FetchedAt   time.Time     `json:"fetched_at,omitempty"`
+	ExpiresAt   time.Time     `json:"expires_at,omitempty"`
+}
+
+var LinkPreviewNotExists = errors.New("link preview does not exist")
+
+// queuePreviews Marks urls that were never fetched, or whose cached preview expired, as pending
+func (dbStructure *DBStructure) queuePreviews(entities []Entity, now time.Time) {
+	for _, entity := range entities {
+		if entity.Type != EntityURL {
+			continue
+		}
+		preview, exists := dbStructure.LinkPreviews[entity.URL]
+		if exists && (preview.Status == PreviewPending || now.Before(preview.ExpiresAt)) {
+			continue
+		}
+		preview.URL = entity.URL
+		preview.Status = PreviewPending
+		dbStructure.LinkPreviews[entity.URL] = preview
+	}
+}
+
+// withPreviews Embeds the fetched previews of the chirp links, an expired preview is still shown until it is refreshed
+func (dbStructure *DBStructure) withPreviews(chirp Chirp) Chirp {
+	chirp.Previews = nil
+	for _, entity := range chirp.Entities {
+		if entity.Type != EntityURL {
+			continue
+		}
+		preview, exists := dbStructure.LinkPreviews[entity.URL]
+		if !exists || preview.FetchedAt.IsZero() || preview.Status == PreviewFailed {
+			continue
+		}
+		if slices.ContainsFunc(chirp.Previews, func(p LinkPreview) bool { return p.URL == preview.URL }) {
+			continue
+		}
+		chirp.Previews = append(chirp.Previews, preview)
+	}
+	return chirp
+}
+
+// PendingLinkPreviews Urls waiting to be fetched, sorted so the fetcher goes through them in a stable order
+func (db *DB) PendingLinkPreviews() ([]LinkPreview, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to retrieve pending link previews: %q", err)
+		return nil, err
+	}
+
+	pending := make([]LinkPreview, 0)
+	for _, preview := range dbStructure.LinkPreviews {
+		if preview.Status == PreviewPending {
+			pending = append(pending, preview)
+		}
+	}
+	slices.SortFunc(pending, func(a, b LinkPreview) int {
+		return cmp.Compare(a.URL, b.URL)
+	})
+
+	return pending, nil
+}
+
+// SaveLinkPreview Stores the result of fetching a pending url
+func (db *DB) SaveLinkPreview(preview LinkPreview) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, exists := dbStructure.LinkPreviews[preview.URL]; !exists {
+			return LinkPreviewNotExists
+		}
+		dbStructure.LinkPreviews[preview.URL] = preview
+		return nil
+	})
+}
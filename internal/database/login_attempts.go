@@ -0,0 +1,78 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+// loginAttemptTTL How long failures are remembered once the lockout, if any, is over
+const loginAttemptTTL = 24 * time.Hour
+
+type LoginAttempt struct {
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
+
+func (a LoginAttempt) expired(now time.Time) bool {
+	return a.LockedUntil.Before(now) && a.LastFailureAt.Add(loginAttemptTTL).Before(now)
+}
+
+// LoginLockedUntil Latest lockout among keys, zero time when none of them is locked.
+func (db *DB) LoginLockedUntil(keys ...string) (time.Time, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to check login lockout: %q", err)
+		return time.Time{}, err
+	}
+
+	lockedUntil := time.Time{}
+	for _, key := range keys {
+		if attempt, exists := dbStructure.LoginAttempts[key]; exists && attempt.LockedUntil.After(lockedUntil) {
+			lockedUntil = attempt.LockedUntil
+		}
+	}
+
+	return lockedUntil, nil
+}
+
+// RecordLoginFailure Increments the failures of key, lockout decides how long it gets locked for after them.
+func (db *DB) RecordLoginFailure(key string, now time.Time, lockout func(failures int) time.Duration) (LoginAttempt, error) {
+
+	attempt := LoginAttempt{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		attempt, exists = dbStructure.LoginAttempts[key]
+		if !exists || attempt.expired(now) {
+			attempt = LoginAttempt{Key: key}
+		}
+
+		attempt.Failures++
+		attempt.LastFailureAt = now
+		if lockFor := lockout(attempt.Failures); lockFor > 0 {
+			attempt.LockedUntil = now.Add(lockFor)
+			log.Printf("Locking logins for %q during %v after %d failures", key, lockFor, attempt.Failures)
+		}
+		dbStructure.LoginAttempts[key] = attempt
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not record login failure: %q", err)
+		return LoginAttempt{}, err
+	}
+
+	return attempt, nil
+}
+
+func (db *DB) ResetLoginFailures(key string) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, exists := dbStructure.LoginAttempts[key]; !exists {
+			return errNoChange
+		}
+		delete(dbStructure.LoginAttempts, key)
+		return nil
+	})
+}
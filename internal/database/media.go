@@ -0,0 +1,59 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Media An uploaded file, its bytes live in the blob store under the same id
+type Media struct {
+	Id          string    `json:"id"`
+	OwnerId     int       `json:"owner_id"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+var MediaNotExists = errors.New("media does not exist")
+var ErrInvalidMedia = errors.New("media does not exist or belongs to another user")
+
+func (db *DB) CreateMedia(media Media) (Media, error) {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		if owner, exists := dbStructure.Users[media.OwnerId]; !exists || owner.Deleted() {
+			return UserNotExists
+		}
+
+		dbStructure.Media[media.Id] = media
+		return nil
+	})
+	if err != nil {
+		return Media{}, err
+	}
+
+	log.Printf("Saved media %s of user with id %d", media.Id, media.OwnerId)
+	return media, nil
+}
+
+func (db *DB) MediaById(id string) (Media, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to find media: %q", err)
+		return Media{}, err
+	}
+
+	media, exists := dbStructure.Media[id]
+	if !exists {
+		return Media{}, MediaNotExists
+	}
+
+	if owner, exists := dbStructure.Users[media.OwnerId]; !exists || owner.Deleted() {
+		return Media{}, MediaNotExists
+	}
+
+	return media, nil
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+// Conversation A one to one conversation, there is at most one for every pair of users
+type Conversation struct {
+	Id           string        `json:"id"`
+	Participants []Participant `json:"participants"`
+	// LastSeq Sequence number of the latest message, zero while the conversation is empty
+	LastSeq       int        `json:"last_seq"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+}
+
+// Participant LastReadSeq is the read receipt shown to the other participant
+type Participant struct {
+	UserId      int        `json:"user_id"`
+	LastReadSeq int        `json:"last_read_seq"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// ConversationSummary A conversation as seen by one of its participants
+type ConversationSummary struct {
+	Conversation
+	Unread int `json:"unread"`
+}
+
+// Message Seq orders the messages of a conversation starting at one
+type Message struct {
+	Id             string    `json:"id"`
+	ConversationId string    `json:"conversation_id"`
+	Seq            int       `json:"seq"`
+	SenderId       int       `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+var ConversationNotExists = errors.New("conversation does not exist")
+var ErrSelfMessage = errors.New("users can not message themselves")
+var ErrNotFollower = errors.New("the recipient does not follow the sender")
+
+func (conversation Conversation) participant(userId int) (int, bool) {
+	for i, participant := range conversation.Participants {
+		if participant.UserId == userId {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (conversation Conversation) otherParticipant(userId int) int {
+	for _, participant := range conversation.Participants {
+		if participant.UserId != userId {
+			return participant.UserId
+		}
+	}
+	return 0
+}
+
+// mayMessage Blocks always win, otherwise the recipient has to follow the sender unless anyone is set
+func (dbStructure *DBStructure) mayMessage(senderId, recipientId int, anyone bool) error {
+	if recipient, exists := dbStructure.Users[recipientId]; !exists || recipient.Deleted() {
+		return UserNotExists
+	}
+	if dbStructure.blocked(senderId, recipientId) {
+		return ErrBlocked
+	}
+	if _, follows := dbStructure.Follows[followKey(recipientId, senderId)]; !follows && !anyone {
+		return ErrNotFollower
+	}
+	return nil
+}
+
+func (dbStructure *DBStructure) conversationBetween(a, b int) (Conversation, bool) {
+	for _, conversation := range dbStructure.Conversations {
+		_, hasA := conversation.participant(a)
+		_, hasB := conversation.participant(b)
+		if hasA && hasB {
+			return conversation, true
+		}
+	}
+	return Conversation{}, false
+}
+
+// summarize Sending a message moves the read receipt of the sender up to it, so every message after
+// the receipt of userId was sent by the other participant and the unread count follows from the sequence numbers.
+func summarize(conversation Conversation, userId int) ConversationSummary {
+	i, _ := conversation.participant(userId)
+	return ConversationSummary{
+		Conversation: conversation,
+		Unread:       conversation.LastSeq - conversation.Participants[i].LastReadSeq,
+	}
+}
+
+// StartConversation Returns the existing conversation between both users when there is one, created tells them apart.
+// The sender has to be allowed to message the recipient either way.
+func (db *DB) StartConversation(id string, senderId, recipientId int, anyone bool, now time.Time) (ConversationSummary, bool, error) {
+
+	if senderId == recipientId {
+		return ConversationSummary{}, false, ErrSelfMessage
+	}
+
+	summary := ConversationSummary{}
+	created := false
+	err := db.update(func(dbStructure *DBStructure) error {
+		if sender, exists := dbStructure.Users[senderId]; !exists || sender.Deleted() {
+			return UserNotExists
+		}
+		err := dbStructure.mayMessage(senderId, recipientId, anyone)
+		if err != nil {
+			return err
+		}
+
+		if conversation, exists := dbStructure.conversationBetween(senderId, recipientId); exists {
+			summary = summarize(conversation, senderId)
+			return errNoChange
+		}
+
+		conversation := Conversation{
+			Id: id,
+			Participants: []Participant{
+				{UserId: min(senderId, recipientId)},
+				{UserId: max(senderId, recipientId)},
+			},
+			CreatedAt: now,
+		}
+		dbStructure.Conversations[id] = conversation
+		summary = ConversationSummary{Conversation: conversation}
+		created = true
+		return nil
+	})
+	if err != nil {
+		return ConversationSummary{}, false, err
+	}
+
+	if created {
+		log.Printf("User with id %d started conversation %s with user with id %d", senderId, id, recipientId)
+	}
+	return summary, created, nil
+}
+
+// ConversationsOf Conversations with the latest activity first
+func (db *DB) ConversationsOf(userId int) ([]ConversationSummary, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list conversations: %q", err)
+		return nil, err
+	}
+
+	conversations := make([]ConversationSummary, 0)
+	for _, conversation := range dbStructure.Conversations {
+		if _, ok := conversation.participant(userId); ok {
+			conversations = append(conversations, summarize(conversation, userId))
+		}
+	}
+	slices.SortFunc(conversations, func(a, b ConversationSummary) int {
+		return cmp.Or(lastActivity(b.Conversation).Compare(lastActivity(a.Conversation)), cmp.Compare(a.Id, b.Id))
+	})
+
+	return conversations, nil
+}
+
+func lastActivity(conversation Conversation) time.Time {
+	if conversation.LastMessageAt != nil {
+		return *conversation.LastMessageAt
+	}
+	return conversation.CreatedAt
+}
+
+// SendMessage The sender has to be a participant and still be allowed to message the other one,
+// sending a message also marks everything before it as read by the sender.
+func (db *DB) SendMessage(message Message, anyone bool) (Message, error) {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		conversation, exists := dbStructure.Conversations[message.ConversationId]
+		if !exists {
+			return ConversationNotExists
+		}
+		i, ok := conversation.participant(message.SenderId)
+		if !ok {
+			return ConversationNotExists
+		}
+		err := dbStructure.mayMessage(message.SenderId, conversation.otherParticipant(message.SenderId), anyone)
+		if err != nil {
+			return err
+		}
+
+		conversation.LastSeq++
+		message.Seq = conversation.LastSeq
+		conversation.LastMessageAt = &message.CreatedAt
+		conversation.Participants[i].LastReadSeq = message.Seq
+		conversation.Participants[i].ReadAt = &message.CreatedAt
+		dbStructure.Conversations[conversation.Id] = conversation
+		dbStructure.Messages[message.Id] = message
+		return nil
+	})
+	if err != nil {
+		return Message{}, err
+	}
+
+	return message, nil
+}
+
+// Messages A page of at most limit messages older than before, newest first. A zero before starts from the latest message.
+func (db *DB) Messages(conversationId string, userId, before, limit int) ([]Message, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list messages: %q", err)
+		return nil, err
+	}
+
+	conversation, exists := dbStructure.Conversations[conversationId]
+	if !exists {
+		return nil, ConversationNotExists
+	}
+	if _, ok := conversation.participant(userId); !ok {
+		return nil, ConversationNotExists
+	}
+
+	messages := make([]Message, 0)
+	for _, message := range dbStructure.Messages {
+		if message.ConversationId == conversationId && (before == 0 || message.Seq < before) {
+			messages = append(messages, message)
+		}
+	}
+	slices.SortFunc(messages, func(a, b Message) int { return b.Seq - a.Seq })
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	return messages, nil
+}
+
+// MarkConversationRead Moves the read receipt of the user up to seq, or to the latest message when it is zero.
+// Receipts never move backwards.
+func (db *DB) MarkConversationRead(conversationId string, userId, seq int, now time.Time) (ConversationSummary, error) {
+
+	summary := ConversationSummary{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		conversation, exists := dbStructure.Conversations[conversationId]
+		if !exists {
+			return ConversationNotExists
+		}
+		i, ok := conversation.participant(userId)
+		if !ok {
+			return ConversationNotExists
+		}
+
+		if seq == 0 || seq > conversation.LastSeq {
+			seq = conversation.LastSeq
+		}
+		if seq <= conversation.Participants[i].LastReadSeq {
+			summary = summarize(conversation, userId)
+			return errNoChange
+		}
+		conversation.Participants[i].LastReadSeq = seq
+		conversation.Participants[i].ReadAt = &now
+		dbStructure.Conversations[conversationId] = conversation
+		summary = summarize(conversation, userId)
+		return nil
+	})
+	if err != nil {
+		return ConversationSummary{}, err
+	}
+
+	return summary, nil
+}
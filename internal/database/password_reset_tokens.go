@@ -0,0 +1,88 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+type PasswordResetToken struct {
+	UserId    int       `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var ErrInvalidResetToken = errors.New("password reset token does not exist or expired")
+
+// SavePasswordResetToken Stores the hash of a reset token, replacing any token previously issued to the user.
+func (db *DB) SavePasswordResetToken(userId int, tokenHash string, expiresAt time.Time) error {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		if _, exists := dbStructure.Users[userId]; !exists {
+			return UserNotExists
+		}
+
+		for hash, resetToken := range dbStructure.PasswordResetTokens {
+			if resetToken.UserId == userId {
+				delete(dbStructure.PasswordResetTokens, hash)
+			}
+		}
+
+		dbStructure.PasswordResetTokens[tokenHash] = PasswordResetToken{
+			UserId:    userId,
+			TokenHash: tokenHash,
+			ExpiresAt: expiresAt,
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, UserNotExists) {
+		log.Printf("Could not save password reset token: %q", err)
+	}
+
+	return err
+}
+
+// ResetPassword Consumes the reset token, stores the new password hash and revokes every refresh token of the user.
+func (db *DB) ResetPassword(tokenHash, hashedPassword string) (userId int, err error) {
+
+	// Single use, even if it turns out to be expired or the user is gone
+	consumedOnly := false
+	err = db.update(func(dbStructure *DBStructure) error {
+		resetToken, exists := dbStructure.PasswordResetTokens[tokenHash]
+		if !exists {
+			log.Print("Received password reset token was not present in the database")
+			return ErrInvalidResetToken
+		}
+		delete(dbStructure.PasswordResetTokens, tokenHash)
+
+		user, exists := dbStructure.Users[resetToken.UserId]
+		if resetToken.ExpiresAt.Before(time.Now()) || !exists {
+			consumedOnly = true
+			return nil
+		}
+
+		user.HashedPassword = hashedPassword
+		dbStructure.Users[user.Id] = user
+
+		for rt, refreshToken := range dbStructure.RefreshTokens {
+			if refreshToken.UserId == user.Id {
+				delete(dbStructure.RefreshTokens, rt)
+			}
+		}
+
+		userId = user.Id
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrInvalidResetToken) {
+			log.Printf("Could not reset password: %q", err)
+		}
+		return 0, err
+	}
+	if consumedOnly {
+		return 0, ErrInvalidResetToken
+	}
+
+	log.Printf("Succesfully reset password for user with id %d", userId)
+	return userId, nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+type PurgeStats struct {
+	RefreshTokens       int
+	PasswordResetTokens int
+	LoginAttempts       int
+	Exports             int
+	WebhookEvents       int
+	WebhookDeliveries   int
+
+	// ExportPaths Archives of the purged exports, they live outside of the database and have to be removed too
+	ExportPaths []string
+}
+
+func (s PurgeStats) Total() int {
+	return s.RefreshTokens + s.PasswordResetTokens + s.LoginAttempts + s.Exports + s.WebhookEvents + s.WebhookDeliveries
+}
+
+// PurgeExpired Deletes every record whose time to live ended before now.
+func (db *DB) PurgeExpired(now time.Time) (PurgeStats, error) {
+
+	stats := PurgeStats{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		for rt, refreshToken := range dbStructure.RefreshTokens {
+			if refreshToken.ExpiresAt.Before(now) {
+				delete(dbStructure.RefreshTokens, rt)
+				stats.RefreshTokens++
+			}
+		}
+
+		for hash, resetToken := range dbStructure.PasswordResetTokens {
+			if resetToken.ExpiresAt.Before(now) {
+				delete(dbStructure.PasswordResetTokens, hash)
+				stats.PasswordResetTokens++
+			}
+		}
+
+		for key, attempt := range dbStructure.LoginAttempts {
+			if attempt.expired(now) {
+				delete(dbStructure.LoginAttempts, key)
+				stats.LoginAttempts++
+			}
+		}
+
+		for id, export := range dbStructure.Exports {
+			if export.ExpiresAt.Before(now) {
+				delete(dbStructure.Exports, id)
+				stats.Exports++
+				if export.Path != "" {
+					stats.ExportPaths = append(stats.ExportPaths, export.Path)
+				}
+			}
+		}
+
+		for id, event := range dbStructure.WebhookInbox {
+			if event.Status == InboxProcessed && event.ExpiresAt.Before(now) {
+				delete(dbStructure.WebhookInbox, id)
+				stats.WebhookEvents++
+			}
+		}
+
+		for id, delivery := range dbStructure.WebhookDeliveries {
+			if delivery.Status != DeliveryPending && delivery.ExpiresAt.Before(now) {
+				delete(dbStructure.WebhookDeliveries, id)
+				stats.WebhookDeliveries++
+			}
+		}
+
+		if stats.Total() == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not purge expired records: %q", err)
+		return PurgeStats{}, err
+	}
+
+	return stats, nil
+}
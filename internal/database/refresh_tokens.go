@@ -35,21 +35,16 @@ func (db *DB) UserIdFromRefreshToken(rt string) (userId int, err error) {
 
 func (db *DB) SaveToken(userId int, rt string) error {
 
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		log.Printf("Could not load database to save tokens: %q", err)
-		return err
-	}
-
-	dbStructure.RefreshTokens[rt] = RefreshToken{
-		UserId:    userId,
-		Token:     rt,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
-	}
-
-	err = db.writeDB(dbStructure)
+	err := db.update(func(dbStructure *DBStructure) error {
+		dbStructure.RefreshTokens[rt] = RefreshToken{
+			UserId:    userId,
+			Token:     rt,
+			ExpiresAt: time.Now().Add(24 * time.Hour),
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("Could not write database to save refresh token: %q", err)
+		log.Printf("Could not save refresh token: %q", err)
 		return err
 	}
 
@@ -58,21 +53,11 @@ func (db *DB) SaveToken(userId int, rt string) error {
 
 func (db *DB) RevokeRefreshToken(rt string) error {
 
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		log.Printf("Could not load database to refresh token: %q", err)
-		return err
-	}
-
-	if _, exists := dbStructure.RefreshTokens[rt]; exists {
-		delete(dbStructure.RefreshTokens, rt)
-		err = db.writeDB(dbStructure)
-		if err != nil {
-			log.Printf("Could not write database after revoking refresh token: %q", err)
-			return err
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, exists := dbStructure.RefreshTokens[rt]; !exists {
+			return errors.New("did not find refresh token to revoke")
 		}
+		delete(dbStructure.RefreshTokens, rt)
 		return nil
-	}
-
-	return errors.New("did not find refresh token to revoke")
+	})
 }
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+var ErrNotScheduled = errors.New("chirp is not scheduled")
+
+// scheduledChirp Only the author can see or change a chirp before it is published
+func (dbStructure *DBStructure) scheduledChirp(chirpId, authorId int) (Chirp, error) {
+	chirp, exists := dbStructure.Chirps[chirpId]
+	if !exists || chirp.AuthorId != authorId {
+		return Chirp{}, ChirpNotExists
+	}
+	if !chirp.Scheduled() {
+		return Chirp{}, ErrNotScheduled
+	}
+	return chirp, nil
+}
+
+// ScheduledChirps The chirps of the author waiting to be published, the next one first
+func (db *DB) ScheduledChirps(authorId int) ([]Chirp, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to retrieve scheduled chirps: %q", err)
+		return nil, err
+	}
+
+	scheduled := make([]Chirp, 0)
+	for _, chirp := range dbStructure.Chirps {
+		if chirp.AuthorId == authorId && chirp.Scheduled() {
+			scheduled = append(scheduled, dbStructure.presentChirp(chirp))
+		}
+	}
+	slices.SortFunc(scheduled, func(a, b Chirp) int {
+		return cmp.Or(a.PublishAt.Compare(*b.PublishAt), cmp.Compare(a.Id, b.Id))
+	})
+
+	return scheduled, nil
+}
+
+func (db *DB) ScheduledChirpById(chirpId, authorId int) (Chirp, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to retrieve scheduled chirp: %q", err)
+		return Chirp{}, err
+	}
+
+	chirp, err := dbStructure.scheduledChirp(chirpId, authorId)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return dbStructure.presentChirp(chirp), nil
+}
+
+// UpdateScheduledChirp Replaces the body and publish time of a chirp that was not published yet
+func (db *DB) UpdateScheduledChirp(chirpId, authorId int, body string, entities []Entity, publishAt time.Time) (Chirp, error) {
+
+	chirp := Chirp{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var err error
+		chirp, err = dbStructure.scheduledChirp(chirpId, authorId)
+		if err != nil {
+			return err
+		}
+
+		chirp.Body = body
+		chirp.Entities = dbStructure.resolveMentions(entities, chirp.AuthorId)
+		chirp.PublishAt = &publishAt
+		dbStructure.Chirps[chirpId] = chirp
+		dbStructure.queuePreviews(entities, time.Now().UTC())
+		chirp = dbStructure.presentChirp(chirp)
+		return nil
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (db *DB) CancelScheduledChirp(chirpId, authorId int) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		if _, err := dbStructure.scheduledChirp(chirpId, authorId); err != nil {
+			return err
+		}
+		delete(dbStructure.Chirps, chirpId)
+		return nil
+	})
+}
+
+// PublishDueChirps Publishes every chirp scheduled at or before now. It also returns when the next
+// scheduled chirp is due, the zero time when there is none.
+func (db *DB) PublishDueChirps(now time.Time) ([]Chirp, time.Time, error) {
+
+	published := make([]Chirp, 0)
+	var next time.Time
+	err := db.update(func(dbStructure *DBStructure) error {
+		for id, chirp := range dbStructure.Chirps {
+			if !chirp.Scheduled() || dbStructure.authorDeleted(chirp) {
+				continue
+			}
+			if chirp.PublishAt.After(now) {
+				if next.IsZero() || chirp.PublishAt.Before(next) {
+					next = *chirp.PublishAt
+				}
+				continue
+			}
+			chirp.PublishAt = nil
+			dbStructure.Chirps[id] = chirp
+			published = append(published, dbStructure.presentChirp(chirp))
+		}
+
+		if len(published) == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not publish scheduled chirps: %q", err)
+		return nil, time.Time{}, err
+	}
+	slices.SortFunc(published, func(a, b Chirp) int { return cmp.Compare(a.Id, b.Id) })
+
+	return published, next, nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive SubscriptionStatus = "active"
+	// SubscriptionPastDue A payment failed, the membership lasts until the period ends unless it is renewed
+	SubscriptionPastDue SubscriptionStatus = "past_due"
+	// SubscriptionCancelled Cancelled memberships last until the period ends
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+	SubscriptionExpired   SubscriptionStatus = "expired"
+)
+
+type SubscriptionChange struct {
+	Event     string             `json:"event"`
+	Status    SubscriptionStatus `json:"status"`
+	PeriodEnd time.Time          `json:"period_end"`
+	At        time.Time          `json:"at"`
+}
+
+// Subscription The Chirpy Red membership of a user, IsChirpyRed is derived from it
+type Subscription struct {
+	Status           SubscriptionStatus   `json:"status"`
+	CurrentPeriodEnd time.Time            `json:"current_period_end"`
+	History          []SubscriptionChange `json:"history"`
+}
+
+func (s *Subscription) Active(now time.Time) bool {
+	return s != nil && s.Status != SubscriptionExpired && now.Before(s.CurrentPeriodEnd)
+}
+
+// UpdateSubscription Records the change in the history and derives the Chirpy Red status from the result
+func (db *DB) UpdateSubscription(userId int, change SubscriptionChange) (User, error) {
+
+	user := User{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		user, exists = dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if user.Subscription == nil {
+			user.Subscription = &Subscription{}
+		}
+		user.Subscription.Status = change.Status
+		user.Subscription.CurrentPeriodEnd = change.PeriodEnd
+		user.Subscription.History = append(user.Subscription.History, change)
+		user.IsChirpyRed = user.Subscription.Active(change.At)
+		dbStructure.Users[userId] = user
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, UserNotExists) {
+			log.Printf("Could not update subscription: %q", err)
+		}
+		return User{}, err
+	}
+
+	log.Printf("Subscription of user with id %d is %s until %v after %q", userId, change.Status, change.PeriodEnd, change.Event)
+	return user, nil
+}
+
+// MigrateLegacySubscriptions Chirpy Red members upgraded before subscriptions were tracked have no Subscription,
+// they get one that lasts until periodEnd and then expires unless it is renewed. Returns how many were migrated.
+func (db *DB) MigrateLegacySubscriptions(now, periodEnd time.Time) (int, error) {
+
+	migrated := 0
+	err := db.update(func(dbStructure *DBStructure) error {
+		for id, user := range dbStructure.Users {
+			if !user.IsChirpyRed || user.Subscription != nil {
+				continue
+			}
+
+			change := SubscriptionChange{
+				Event:     "migrated",
+				Status:    SubscriptionActive,
+				PeriodEnd: periodEnd,
+				At:        now,
+			}
+			user.Subscription = &Subscription{
+				Status:           change.Status,
+				CurrentPeriodEnd: change.PeriodEnd,
+				History:          []SubscriptionChange{change},
+			}
+			dbStructure.Users[id] = user
+			migrated++
+		}
+
+		if migrated == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not migrate legacy subscriptions: %q", err)
+		return 0, err
+	}
+
+	return migrated, nil
+}
+
+// ExpireSubscriptions Ends every membership whose period lapsed before now, returns the ids of their users
+func (db *DB) ExpireSubscriptions(now time.Time) ([]int, error) {
+
+	expired := make([]int, 0)
+	err := db.update(func(dbStructure *DBStructure) error {
+		for id, user := range dbStructure.Users {
+			if user.Subscription == nil || user.Subscription.Status == SubscriptionExpired || user.Subscription.Active(now) {
+				continue
+			}
+
+			user.Subscription.Status = SubscriptionExpired
+			user.Subscription.History = append(user.Subscription.History, SubscriptionChange{
+				Event:     "expired",
+				Status:    SubscriptionExpired,
+				PeriodEnd: user.Subscription.CurrentPeriodEnd,
+				At:        now,
+			})
+			user.IsChirpyRed = false
+			dbStructure.Users[id] = user
+			expired = append(expired, id)
+		}
+
+		if len(expired) == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not expire subscriptions: %q", err)
+		return nil, err
+	}
+
+	return expired, nil
+}
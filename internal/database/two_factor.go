@@ -0,0 +1,111 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"slices"
+)
+
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+var ErrTwoFactorNotPending = errors.New("two-factor authentication setup was not started")
+var ErrTOTPCodeReused = errors.New("totp code was already used")
+var ErrInvalidRecoveryCode = errors.New("recovery code is not valid")
+
+func (db *DB) SetPendingTOTPSecret(userId int, secret string) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if user.TwoFactorEnabled {
+			return ErrTwoFactorEnabled
+		}
+
+		user.PendingTOTPSecret = secret
+		dbStructure.Users[userId] = user
+		return nil
+	})
+}
+
+// EnableTwoFactor Promotes the pending secret once the user proved their authenticator works, step is the time
+// step of the code used to confirm it.
+func (db *DB) EnableTwoFactor(userId int, step int64, recoveryCodeHashes []string) error {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if user.TwoFactorEnabled {
+			return ErrTwoFactorEnabled
+		}
+
+		if user.PendingTOTPSecret == "" {
+			return ErrTwoFactorNotPending
+		}
+
+		user.TwoFactorEnabled = true
+		user.TOTPSecret = user.PendingTOTPSecret
+		user.PendingTOTPSecret = ""
+		user.TOTPLastStep = step
+		user.RecoveryCodes = recoveryCodeHashes
+		dbStructure.Users[userId] = user
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Enabled two-factor authentication for user with id %d", userId)
+	return nil
+}
+
+// UseTOTPStep Records the time step of an accepted code, a code can only be used once.
+func (db *DB) UseTOTPStep(userId int, step int64) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if step <= user.TOTPLastStep {
+			log.Printf("User with id %d tried to reuse a totp code", userId)
+			return ErrTOTPCodeReused
+		}
+
+		user.TOTPLastStep = step
+		dbStructure.Users[userId] = user
+		return nil
+	})
+}
+
+func (db *DB) UseRecoveryCode(userId int, codeHash string) error {
+
+	left := 0
+	err := db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		i := slices.Index(user.RecoveryCodes, codeHash)
+		if i == -1 {
+			return ErrInvalidRecoveryCode
+		}
+
+		user.RecoveryCodes = slices.Delete(user.RecoveryCodes, i, i+1)
+		dbStructure.Users[userId] = user
+		left = len(user.RecoveryCodes)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("User with id %d used a recovery code, %d left", userId, left)
+	return nil
+}
@@ -4,49 +4,84 @@ import (
 	"errors"
 	"github.com/benjamin-vq/chirpy/internal/assert"
 	"log"
+	"strings"
+	"time"
 )
 
 type User struct {
 	Email          string `json:"email"`
 	HashedPassword string `json:"hashedPassword"`
 	Id             int    `json:"id"`
-	IsChirpyRed    bool   `json:"is_chirpy_red"`
+	// IsChirpyRed Derived from Subscription, never set it directly
+	IsChirpyRed   bool `json:"is_chirpy_red"`
+	EmailVerified bool `json:"email_verified"`
+
+	// Handle Unique ignoring case, stored as the user typed it
+	Handle      string `json:"handle,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	// PinnedChirpId Shown first in the chirps of the user, zero when nothing is pinned
+	PinnedChirpId int `json:"pinned_chirp_id,omitempty"`
+
+	TwoFactorEnabled  bool     `json:"two_factor_enabled"`
+	TOTPSecret        string   `json:"totp_secret,omitempty"`
+	PendingTOTPSecret string   `json:"pending_totp_secret,omitempty"`
+	TOTPLastStep      int64    `json:"totp_last_step,omitempty"`
+	RecoveryCodes     []string `json:"recovery_codes,omitempty"`
+
+	Subscription *Subscription `json:"subscription,omitempty"`
+
+	// DeletedAt Set while the account waits for the grace period to end before being purged
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (u User) Deleted() bool {
+	return u.DeletedAt != nil
 }
 
 var ErrEmailExists = errors.New("email already exists")
 var UserNotExists = errors.New("user does not exist")
+var ErrHandleExists = errors.New("handle already exists")
+var ErrEmailChanged = errors.New("email changed since verification was requested")
+var ErrPasswordChanged = errors.New("password changed since it was verified")
 
 func (db *DB) CreateUser(email, hashedPassword string) (User, error) {
 
 	assert.That(email != "", "email can not be empty")
 
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		log.Printf("Could not load database file to retrieve users: %q", err)
-		return User{}, err
-	}
-
-	for id, user := range dbStructure.Users {
-		if user.Email == email {
-			log.Printf("Email %q already exists for user with id %d", email, id)
-			return User{}, ErrEmailExists
+	user := User{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		for id, user := range dbStructure.Users {
+			if strings.EqualFold(user.Email, email) {
+				log.Printf("Email %q already exists for user with id %d", email, id)
+				return ErrEmailExists
+			}
 		}
-	}
-
-	userId := len(dbStructure.Users) + 1
-	user := User{
-		Email:          email,
-		HashedPassword: hashedPassword,
-		Id:             userId,
-		IsChirpyRed:    false,
-	}
 
-	assert.That(dbStructure.Users != nil, "Users map should be initialized")
-	dbStructure.Users[userId] = user
-	err = db.writeDB(dbStructure)
+		// Purged users leave gaps, counting users could hand out an id that is still in use
+		var userId int
+		for id := range dbStructure.Users {
+			if id > userId {
+				userId = id
+			}
+		}
+		userId += 1
+		user = User{
+			Email:          email,
+			HashedPassword: hashedPassword,
+			Id:             userId,
+			IsChirpyRed:    false,
+		}
 
+		assert.That(dbStructure.Users != nil, "Users map should be initialized")
+		dbStructure.Users[userId] = user
+		return nil
+	})
 	if err != nil {
-		log.Printf("Could not write database to save new user: %q", err)
+		if !errors.Is(err, ErrEmailExists) {
+			log.Printf("Could not save new user: %q", err)
+		}
 		return User{}, err
 	}
 
@@ -86,21 +121,54 @@ func (db *DB) UserById(id int) (User, error) {
 	return user, nil
 }
 
-func (db *DB) UpdateUser(user *User) error {
-	assert.That(user != nil, "Attempting to update nil user")
+func (db *DB) UserByHandle(handle string) (User, error) {
 
 	dbStructure, err := db.loadDB()
 	if err != nil {
-		log.Printf("Could not load database to find a user by id: %q", err)
-		return err
+		log.Printf("Could not load database to find a user by handle: %q", err)
+		return User{}, err
 	}
 
-	if _, exists := dbStructure.Users[user.Id]; !exists {
-		return UserNotExists
+	for _, user := range dbStructure.Users {
+		if user.Handle != "" && strings.EqualFold(user.Handle, handle) {
+			return user, nil
+		}
 	}
 
-	dbStructure.Users[user.Id] = *user
-	err = db.writeDB(dbStructure)
+	return User{}, UserNotExists
+}
+
+func (db *DB) UpdateUser(user *User) error {
+	assert.That(user != nil, "Attempting to update nil user")
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		stored, exists := dbStructure.Users[user.Id]
+		if !exists {
+			return UserNotExists
+		}
+
+		if user.PinnedChirpId != 0 && !dbStructure.pinnable(user.Id, user.PinnedChirpId) {
+			if user.PinnedChirpId != stored.PinnedChirpId {
+				return ErrInvalidPin
+			}
+			// The pinned chirp was deleted after the user was loaded
+			user.PinnedChirpId = 0
+		}
+
+		for id, other := range dbStructure.Users {
+			if id != user.Id && strings.EqualFold(other.Email, user.Email) {
+				log.Printf("Email %q already exists for user with id %d", user.Email, id)
+				return ErrEmailExists
+			}
+			if id != user.Id && user.Handle != "" && strings.EqualFold(other.Handle, user.Handle) {
+				log.Printf("Handle %q already exists for user with id %d", user.Handle, id)
+				return ErrHandleExists
+			}
+		}
+
+		dbStructure.Users[user.Id] = *user
+		return nil
+	})
 	if err != nil {
 		return err
 	}
@@ -109,27 +177,136 @@ func (db *DB) UpdateUser(user *User) error {
 	return nil
 }
 
-func (db *DB) MakeChirpyRed(userId int) error {
-	assert.That(userId != 0, "Attempting to upgrade invalid user id to chirpy red")
+// UserPatch Nil fields are left untouched, empty strings clear the profile fields
+type UserPatch struct {
+	Email          *string
+	HashedPassword *string
 
-	dbStructure, err := db.loadDB()
+	Handle        *string
+	DisplayName   *string
+	Bio           *string
+	AvatarURL     *string
+	PinnedChirpId *int
+
+	// KeepRefreshToken Stays valid when the password changes, every other refresh token of the user is revoked
+	KeepRefreshToken string
+}
+
+// PatchUser Applies only the fields present in the patch to the stored user, so concurrent changes to the rest are kept.
+func (db *DB) PatchUser(userId int, patch UserPatch) (User, error) {
+
+	user := User{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		user, exists = dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if patch.PinnedChirpId != nil && *patch.PinnedChirpId != 0 && !dbStructure.pinnable(userId, *patch.PinnedChirpId) {
+			return ErrInvalidPin
+		}
+
+		for id, other := range dbStructure.Users {
+			if id == userId {
+				continue
+			}
+			if patch.Email != nil && strings.EqualFold(other.Email, *patch.Email) {
+				log.Printf("Email %q already exists for user with id %d", *patch.Email, id)
+				return ErrEmailExists
+			}
+			if patch.Handle != nil && *patch.Handle != "" && strings.EqualFold(other.Handle, *patch.Handle) {
+				log.Printf("Handle %q already exists for user with id %d", *patch.Handle, id)
+				return ErrHandleExists
+			}
+		}
+
+		if patch.Email != nil && *patch.Email != user.Email {
+			user.Email = *patch.Email
+			user.EmailVerified = false
+		}
+		if patch.Handle != nil {
+			user.Handle = *patch.Handle
+		}
+		if patch.DisplayName != nil {
+			user.DisplayName = *patch.DisplayName
+		}
+		if patch.Bio != nil {
+			user.Bio = *patch.Bio
+		}
+		if patch.AvatarURL != nil {
+			user.AvatarURL = *patch.AvatarURL
+		}
+		if patch.PinnedChirpId != nil {
+			user.PinnedChirpId = *patch.PinnedChirpId
+		}
+
+		if patch.HashedPassword != nil {
+			user.HashedPassword = *patch.HashedPassword
+			for rt, refreshToken := range dbStructure.RefreshTokens {
+				if refreshToken.UserId == userId && rt != patch.KeepRefreshToken {
+					delete(dbStructure.RefreshTokens, rt)
+				}
+			}
+		}
+
+		dbStructure.Users[userId] = user
+		return nil
+	})
 	if err != nil {
-		log.Printf("Could not load database to find a user by id: %q", err)
-		return err
+		return User{}, err
 	}
 
-	user, exists := dbStructure.Users[userId]
-	if !exists {
-		return UserNotExists
-	}
-	user.IsChirpyRed = true
-	dbStructure.Users[userId] = user
+	log.Printf("Succesfully patched user with id %d", userId)
+	return user, nil
+}
+
+func (db *DB) VerifyEmail(userId int, email string) (User, error) {
+
+	user := User{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		user, exists = dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
 
-	err = db.writeDB(dbStructure)
+		if user.Email != email {
+			log.Printf("User with id %d changed their email before verifying it", userId)
+			return ErrEmailChanged
+		}
+
+		if user.EmailVerified {
+			return errNoChange
+		}
+
+		user.EmailVerified = true
+		dbStructure.Users[userId] = user
+		return nil
+	})
 	if err != nil {
-		return err
+		return User{}, err
 	}
 
-	log.Printf("Succesfully upgraded user with id %d to Chirpy Red", userId)
-	return nil
+	log.Printf("Succesfully verified email of user with id %d", userId)
+	return user, nil
+}
+
+// UpdatePasswordHash Only replaces the hash that was verified, a password changed in the meantime is kept.
+func (db *DB) UpdatePasswordHash(userId int, verifiedHash, hashedPassword string) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		user, exists := dbStructure.Users[userId]
+		if !exists {
+			return UserNotExists
+		}
+
+		if user.HashedPassword != verifiedHash {
+			return ErrPasswordChanged
+		}
+
+		user.HashedPassword = hashedPassword
+		dbStructure.Users[userId] = user
+		return nil
+	})
 }
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// Visibility Who can read a chirp besides its author
+type Visibility string
+
+const (
+	VisibilityPublic    Visibility = "public"
+	VisibilityFollowers Visibility = "followers"
+	// VisibilityMentioned Only the users mentioned in the chirp
+	VisibilityMentioned Visibility = "mentioned"
+)
+
+const EntityMention = "mention"
+
+var ErrInvalidVisibility = errors.New("visibility must be one of public, followers or mentioned")
+
+// ParseVisibility An empty value means public
+func ParseVisibility(value string) (Visibility, error) {
+	switch visibility := Visibility(value); visibility {
+	case "":
+		return VisibilityPublic, nil
+	case VisibilityPublic, VisibilityFollowers, VisibilityMentioned:
+		return visibility, nil
+	}
+	return "", ErrInvalidVisibility
+}
+
+// canView Anonymous readers have viewerId zero and only see public chirps, users that blocked each other see none
+func (dbStructure *DBStructure) canView(chirp Chirp, viewerId int) bool {
+	if viewerId != 0 && chirp.AuthorId == viewerId {
+		return true
+	}
+	if dbStructure.blocked(viewerId, chirp.AuthorId) {
+		return false
+	}
+
+	switch chirp.Visibility {
+	case "", VisibilityPublic:
+		return true
+	case VisibilityFollowers:
+		_, follows := dbStructure.Follows[followKey(viewerId, chirp.AuthorId)]
+		return viewerId != 0 && follows
+	case VisibilityMentioned:
+		return viewerId != 0 && slices.ContainsFunc(chirp.Entities, func(e Entity) bool {
+			return e.Type == EntityMention && e.UserId == viewerId
+		})
+	}
+	return false
+}
+
+// resolveMentions Links every mention to the user with that handle. Mentions of unknown handles, and of users
+// that blocked the author or were blocked by them, are dropped.
+func (dbStructure *DBStructure) resolveMentions(entities []Entity, authorId int) []Entity {
+	resolved := make([]Entity, 0, len(entities))
+	for _, entity := range entities {
+		if entity.Type != EntityMention {
+			resolved = append(resolved, entity)
+			continue
+		}
+
+		entity.UserId = 0
+		for _, user := range dbStructure.Users {
+			if user.Handle != "" && !user.Deleted() && strings.EqualFold(user.Handle, entity.Handle) {
+				entity.UserId = user.Id
+				break
+			}
+		}
+		if entity.UserId != 0 && !dbStructure.blocked(authorId, entity.UserId) {
+			resolved = append(resolved, entity)
+		}
+	}
+
+	if len(resolved) == 0 {
+		return nil
+	}
+	return resolved
+}
+
+// presentChirp Fills in what is derived when reading a chirp, chirps written before visibility existed are public
+func (dbStructure *DBStructure) presentChirp(chirp Chirp) Chirp {
+	if chirp.Visibility == "" {
+		chirp.Visibility = VisibilityPublic
+	}
+	return dbStructure.withPreviews(chirp)
+}
@@ -0,0 +1,312 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+// WebhookEndpoint A URL outbound events are delivered to. Endpoints of users only receive events about that user,
+// endpoints registered by admins (OwnerId 0) receive every event.
+type WebhookEndpoint struct {
+	Id      string   `json:"id"`
+	OwnerId int      `json:"owner_id"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Enabled bool     `json:"enabled"`
+
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+func (e WebhookEndpoint) receives(event string, userId int) bool {
+	return e.Enabled && (e.OwnerId == 0 || e.OwnerId == userId) && slices.Contains(e.Events, event)
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// DeliveryAttempt One line of the delivery log
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type WebhookDelivery struct {
+	Id         string `json:"id"`
+	EndpointId string `json:"endpoint_id"`
+	Event      string `json:"event"`
+	Payload    string `json:"payload"`
+
+	Status        DeliveryStatus    `json:"status"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	// ExpiresAt Set once the delivery succeeded or failed for good, the log is kept until then
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var WebhookEndpointNotExists = errors.New("webhook endpoint does not exist")
+var WebhookDeliveryNotExists = errors.New("webhook delivery does not exist")
+
+func (db *DB) CreateWebhookEndpoint(endpoint WebhookEndpoint) (WebhookEndpoint, error) {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		if endpoint.OwnerId != 0 {
+			if owner, exists := dbStructure.Users[endpoint.OwnerId]; !exists || owner.Deleted() {
+				return UserNotExists
+			}
+		}
+
+		endpoint.Enabled = true
+		dbStructure.WebhookEndpoints[endpoint.Id] = endpoint
+		return nil
+	})
+	if err != nil {
+		return WebhookEndpoint{}, err
+	}
+
+	log.Printf("Registered webhook endpoint %s for owner %d", endpoint.Id, endpoint.OwnerId)
+	return endpoint, nil
+}
+
+// WebhookEndpointsByOwner Oldest first
+func (db *DB) WebhookEndpointsByOwner(ownerId int) ([]WebhookEndpoint, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list webhook endpoints: %q", err)
+		return nil, err
+	}
+
+	endpoints := make([]WebhookEndpoint, 0)
+	for _, endpoint := range dbStructure.WebhookEndpoints {
+		if endpoint.OwnerId == ownerId {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	slices.SortFunc(endpoints, func(a, b WebhookEndpoint) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return endpoints, nil
+}
+
+// WebhookEndpointById Endpoints of other owners do not exist for the caller
+func (db *DB) WebhookEndpointById(id string, ownerId int) (WebhookEndpoint, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to find webhook endpoint: %q", err)
+		return WebhookEndpoint{}, err
+	}
+
+	endpoint, exists := dbStructure.WebhookEndpoints[id]
+	if !exists || endpoint.OwnerId != ownerId {
+		return WebhookEndpoint{}, WebhookEndpointNotExists
+	}
+
+	return endpoint, nil
+}
+
+// DeleteWebhookEndpoint Its delivery log goes with it
+func (db *DB) DeleteWebhookEndpoint(id string, ownerId int) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		endpoint, exists := dbStructure.WebhookEndpoints[id]
+		if !exists || endpoint.OwnerId != ownerId {
+			return WebhookEndpointNotExists
+		}
+
+		dbStructure.deleteWebhookEndpoint(id)
+		return nil
+	})
+}
+
+func (dbStructure *DBStructure) deleteWebhookEndpoint(id string) {
+	delete(dbStructure.WebhookEndpoints, id)
+	for deliveryId, delivery := range dbStructure.WebhookDeliveries {
+		if delivery.EndpointId == id {
+			delete(dbStructure.WebhookDeliveries, deliveryId)
+		}
+	}
+}
+
+// EnableWebhookEndpoint Turns an automatically disabled endpoint back on with a clean failure count
+func (db *DB) EnableWebhookEndpoint(id string, ownerId int) (WebhookEndpoint, error) {
+
+	endpoint := WebhookEndpoint{}
+	err := db.update(func(dbStructure *DBStructure) error {
+		var exists bool
+		endpoint, exists = dbStructure.WebhookEndpoints[id]
+		if !exists || endpoint.OwnerId != ownerId {
+			return WebhookEndpointNotExists
+		}
+
+		endpoint.Enabled = true
+		endpoint.ConsecutiveFailures = 0
+		endpoint.DisabledAt = nil
+		dbStructure.WebhookEndpoints[id] = endpoint
+		return nil
+	})
+	if err != nil {
+		return WebhookEndpoint{}, err
+	}
+
+	return endpoint, nil
+}
+
+// EnqueueWebhookDeliveries Creates a delivery for every endpoint interested in an event about userId,
+// newDeliveryId has to return unique ids.
+func (db *DB) EnqueueWebhookDeliveries(event string, userId int, payload string, now time.Time, newDeliveryId func() (string, error)) (int, error) {
+
+	enqueued := 0
+	err := db.update(func(dbStructure *DBStructure) error {
+		for _, endpoint := range dbStructure.WebhookEndpoints {
+			if !endpoint.receives(event, userId) {
+				continue
+			}
+
+			id, err := newDeliveryId()
+			if err != nil {
+				return err
+			}
+
+			dbStructure.WebhookDeliveries[id] = WebhookDelivery{
+				Id:            id,
+				EndpointId:    endpoint.Id,
+				Event:         event,
+				Payload:       payload,
+				Status:        DeliveryPending,
+				Attempts:      make([]DeliveryAttempt, 0),
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			}
+			enqueued++
+		}
+
+		if enqueued == 0 {
+			return errNoChange
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not enqueue webhook deliveries: %q", err)
+		return 0, err
+	}
+
+	return enqueued, nil
+}
+
+// DueWebhookDeliveries Pending deliveries whose next attempt is not in the future along with their endpoints, oldest first
+func (db *DB) DueWebhookDeliveries(now time.Time) ([]WebhookDelivery, map[string]WebhookEndpoint, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to find due webhook deliveries: %q", err)
+		return nil, nil, err
+	}
+
+	due := make([]WebhookDelivery, 0)
+	endpoints := make(map[string]WebhookEndpoint)
+	for _, delivery := range dbStructure.WebhookDeliveries {
+		if delivery.Status != DeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		due = append(due, delivery)
+		endpoints[delivery.EndpointId] = dbStructure.WebhookEndpoints[delivery.EndpointId]
+	}
+	slices.SortFunc(due, func(a, b WebhookDelivery) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return due, endpoints, nil
+}
+
+// RecordDeliveryAttempt Logs the attempt and updates the delivery and its endpoint. A failed attempt is retried at
+// retryAt unless giveUp is set, the endpoint is disabled once it failed disableAfter times in a row (never when zero).
+// Returns whether the endpoint got disabled.
+func (db *DB) RecordDeliveryAttempt(id string, attempt DeliveryAttempt, succeeded, giveUp bool, retryAt, expiresAt time.Time, disableAfter int) (bool, error) {
+
+	disabled := false
+	err := db.update(func(dbStructure *DBStructure) error {
+		delivery, exists := dbStructure.WebhookDeliveries[id]
+		if !exists {
+			return WebhookDeliveryNotExists
+		}
+		endpoint, exists := dbStructure.WebhookEndpoints[delivery.EndpointId]
+		if !exists {
+			return WebhookEndpointNotExists
+		}
+
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		switch {
+		case succeeded:
+			delivery.Status = DeliverySucceeded
+			delivery.ExpiresAt = expiresAt
+			endpoint.ConsecutiveFailures = 0
+		default:
+			endpoint.ConsecutiveFailures++
+			delivery.NextAttemptAt = retryAt
+			if giveUp {
+				delivery.Status = DeliveryFailed
+				delivery.ExpiresAt = expiresAt
+			}
+			if disableAfter > 0 && endpoint.Enabled && endpoint.ConsecutiveFailures >= disableAfter {
+				endpoint.Enabled = false
+				endpoint.DisabledAt = &attempt.At
+				disabled = true
+			}
+		}
+
+		// Nothing is delivered to a disabled endpoint, its pending deliveries fail right away
+		if !endpoint.Enabled {
+			for otherId, other := range dbStructure.WebhookDeliveries {
+				if other.EndpointId == endpoint.Id && other.Status == DeliveryPending && otherId != id {
+					other.Status = DeliveryFailed
+					other.ExpiresAt = expiresAt
+					dbStructure.WebhookDeliveries[otherId] = other
+				}
+			}
+			if delivery.Status == DeliveryPending {
+				delivery.Status = DeliveryFailed
+				delivery.ExpiresAt = expiresAt
+			}
+		}
+
+		dbStructure.WebhookDeliveries[id] = delivery
+		dbStructure.WebhookEndpoints[endpoint.Id] = endpoint
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return disabled, nil
+}
+
+// WebhookDeliveries The delivery log of an endpoint, newest first
+func (db *DB) WebhookDeliveries(endpointId string) ([]WebhookDelivery, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list webhook deliveries: %q", err)
+		return nil, err
+	}
+
+	deliveries := make([]WebhookDelivery, 0)
+	for _, delivery := range dbStructure.WebhookDeliveries {
+		if delivery.EndpointId == endpointId {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortFunc(deliveries, func(a, b WebhookDelivery) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	return deliveries, nil
+}
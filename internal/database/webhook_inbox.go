@@ -0,0 +1,172 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+type InboxStatus string
+
+const (
+	InboxPending   InboxStatus = "pending"
+	InboxProcessed InboxStatus = "processed"
+	// InboxDead Gave up after too many attempts, waits for an admin to replay it
+	InboxDead InboxStatus = "dead"
+)
+
+// InboxEvent A webhook as it was received, stored before it is acknowledged so it survives restarts
+type InboxEvent struct {
+	Id      string `json:"id"`
+	Source  string `json:"source"`
+	EventId string `json:"event_id"`
+	Payload string `json:"payload"`
+
+	Status        InboxStatus `json:"status"`
+	Attempts      int         `json:"attempts"`
+	LastError     string      `json:"last_error,omitempty"`
+	ReceivedAt    time.Time   `json:"received_at"`
+	NextAttemptAt time.Time   `json:"next_attempt_at"`
+	// ExpiresAt Only set once processed, the event is kept until then to reject replays
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var ErrDuplicateEvent = errors.New("webhook event was already received")
+var InboxEventNotExists = errors.New("webhook event does not exist")
+var ErrEventNotDead = errors.New("only dead webhook events can be replayed")
+
+// EnqueueWebhookEvent Events are deduplicated by the id the sender gave them
+func (db *DB) EnqueueWebhookEvent(event InboxEvent) (InboxEvent, error) {
+
+	err := db.update(func(dbStructure *DBStructure) error {
+		for _, other := range dbStructure.WebhookInbox {
+			if other.Source == event.Source && other.EventId == event.EventId {
+				return ErrDuplicateEvent
+			}
+		}
+
+		event.Status = InboxPending
+		event.NextAttemptAt = event.ReceivedAt
+		dbStructure.WebhookInbox[event.Id] = event
+		return nil
+	})
+	if err != nil {
+		return InboxEvent{}, err
+	}
+
+	return event, nil
+}
+
+func (db *DB) DueWebhookEvents(now time.Time) ([]InboxEvent, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to find due webhook events: %q", err)
+		return nil, err
+	}
+
+	due := make([]InboxEvent, 0)
+	for _, event := range dbStructure.WebhookInbox {
+		if event.Status == InboxPending && !event.NextAttemptAt.After(now) {
+			due = append(due, event)
+		}
+	}
+	slices.SortFunc(due, func(a, b InboxEvent) int { return a.ReceivedAt.Compare(b.ReceivedAt) })
+
+	return due, nil
+}
+
+// WebhookEvents Every event with the given status, or all of them when status is empty, oldest first
+func (db *DB) WebhookEvents(status InboxStatus) ([]InboxEvent, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to list webhook events: %q", err)
+		return nil, err
+	}
+
+	events := make([]InboxEvent, 0)
+	for _, event := range dbStructure.WebhookInbox {
+		if status == "" || event.Status == status {
+			events = append(events, event)
+		}
+	}
+	slices.SortFunc(events, func(a, b InboxEvent) int { return a.ReceivedAt.Compare(b.ReceivedAt) })
+
+	return events, nil
+}
+
+func (db *DB) WebhookEventById(id string) (InboxEvent, error) {
+
+	dbStructure, err := db.loadDB()
+	if err != nil {
+		log.Printf("Could not load database to find webhook event: %q", err)
+		return InboxEvent{}, err
+	}
+
+	event, exists := dbStructure.WebhookInbox[id]
+	if !exists {
+		return InboxEvent{}, InboxEventNotExists
+	}
+
+	return event, nil
+}
+
+func (db *DB) MarkWebhookEventProcessed(id string, expiresAt time.Time) error {
+	return db.updateWebhookEvent(id, func(event *InboxEvent) error {
+		event.Status = InboxProcessed
+		event.Attempts++
+		event.LastError = ""
+		event.ExpiresAt = expiresAt
+		return nil
+	})
+}
+
+// MarkWebhookEventFailed Schedules another attempt at retryAt, or moves the event to the dead letters when dead is set
+func (db *DB) MarkWebhookEventFailed(id string, reason string, retryAt time.Time, dead bool) error {
+	return db.updateWebhookEvent(id, func(event *InboxEvent) error {
+		event.Attempts++
+		event.LastError = reason
+		event.NextAttemptAt = retryAt
+		if dead {
+			event.Status = InboxDead
+		}
+		return nil
+	})
+}
+
+// ReplayWebhookEvent Gives a dead event a fresh set of attempts
+func (db *DB) ReplayWebhookEvent(id string, now time.Time) (InboxEvent, error) {
+
+	replayed := InboxEvent{}
+	err := db.updateWebhookEvent(id, func(event *InboxEvent) error {
+		if event.Status != InboxDead {
+			return ErrEventNotDead
+		}
+		event.Status = InboxPending
+		event.Attempts = 0
+		event.NextAttemptAt = now
+		replayed = *event
+		return nil
+	})
+
+	return replayed, err
+}
+
+func (db *DB) updateWebhookEvent(id string, change func(event *InboxEvent) error) error {
+
+	return db.update(func(dbStructure *DBStructure) error {
+		event, exists := dbStructure.WebhookInbox[id]
+		if !exists {
+			return InboxEventNotExists
+		}
+
+		err := change(&event)
+		if err != nil {
+			return err
+		}
+		dbStructure.WebhookInbox[id] = event
+		return nil
+	})
+}
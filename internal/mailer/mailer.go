@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+var ErrInvalidHeader = errors.New("mail header contains line breaks")
+
+// LogMailer Writes emails to the log, or appends them to Path when it is set. Meant for local development.
+type LogMailer struct {
+	Path string
+}
+
+func (m LogMailer) Send(to, subject, body string) error {
+	msg, err := buildMessage("chirpy@localhost", to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	if m.Path == "" {
+		log.Printf("Sending email:\n%s", msg)
+		return nil
+	}
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Could not open mail log file: %q", err)
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(msg + "\r\n\r\n")
+	return err
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(to, subject, body string) error {
+	msg, err := buildMessage(m.From, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	err = smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+	if err != nil {
+		log.Printf("Could not send email through %s: %q", m.Host, err)
+		return err
+	}
+
+	return nil
+}
+
+func buildMessage(from, to, subject, body string) (string, error) {
+	for _, header := range []string{from, to, subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return "", ErrInvalidHeader
+		}
+	}
+
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+
+	return fmt.Sprintf("%s\r\n\r\n%s", strings.Join(headers, "\r\n"), body), nil
+}
@@ -0,0 +1,93 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore Keeps uploaded files by key, metadata like the content type lives in the database
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+var ErrBlobNotExists = errors.New("blob does not exist")
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// DiskStore Stores every blob as a file named after its key inside Dir
+type DiskStore struct {
+	Dir string
+}
+
+func (s DiskStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\.`) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, key), nil
+}
+
+func (s DiskStore) Put(key string, r io.Reader) error {
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(s.Dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Readers never see a half written file
+	tmp, err := os.CreateTemp(s.Dir, key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s DiskStore) Open(key string) (io.ReadSeekCloser, error) {
+
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotExists
+	}
+	return f, err
+}
+
+// Delete Deleting a missing blob is not an error
+func (s DiskStore) Delete(key string) error {
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// Limits Uploads above any of these are rejected
+type Limits struct {
+	MaxBytes  int64
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Image An upload that passed validation, Data has its metadata stripped
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+var ErrTooLarge = errors.New("file exceeds the size limit")
+var ErrDimensions = errors.New("image dimensions exceed the limit")
+var ErrMalformed = errors.New("image could not be decoded")
+
+// Process The content type is sniffed from the bytes, whatever the client claimed is ignored.
+func Process(data []byte, limits Limits) (Image, error) {
+
+	if limits.MaxBytes > 0 && int64(len(data)) > limits.MaxBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+
+	var stripped []byte
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		stripped, err = stripJPEG(data)
+	case "image/png":
+		stripped, err = stripPNG(data)
+	default:
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	// Checking the header first keeps huge images from being decoded at all
+	config, _, err := image.DecodeConfig(bytes.NewReader(stripped))
+	if err != nil {
+		return Image{}, ErrMalformed
+	}
+	if config.Width <= 0 || config.Height <= 0 ||
+		(limits.MaxWidth > 0 && config.Width > limits.MaxWidth) ||
+		(limits.MaxHeight > 0 && config.Height > limits.MaxHeight) {
+		return Image{}, ErrDimensions
+	}
+
+	_, _, err = image.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return Image{}, ErrMalformed
+	}
+
+	return Image{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Data:        stripped,
+	}, nil
+}
+
+// stripJPEG Keeps only APP0 (JFIF) and APP14 (Adobe, needed for the color transform) of the application segments.
+// EXIF, XMP, ICC and IPTC blocks and comments are dropped.
+func stripJPEG(data []byte) ([]byte, error) {
+
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	i := 2
+	for i < len(data) {
+		if data[i] != 0xFF {
+			return nil, ErrMalformed
+		}
+		// Markers can be padded with any number of fill bytes
+		for i < len(data) && data[i] == 0xFF {
+			i++
+		}
+		if i >= len(data) {
+			return nil, ErrMalformed
+		}
+		marker := data[i]
+		i++
+
+		switch {
+		case marker == 0xD9:
+			return append(out, 0xFF, marker), nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out = append(out, 0xFF, marker)
+			continue
+		}
+
+		if i+2 > len(data) {
+			return nil, ErrMalformed
+		}
+		length := int(binary.BigEndian.Uint16(data[i:]))
+		if length < 2 || i+length > len(data) {
+			return nil, ErrMalformed
+		}
+		segment := data[i : i+length]
+		i += length
+
+		// Entropy coded data follows the start of scan, it is copied up to the next marker. Progressive images
+		// have several scans, and anything after the end of image marker is dropped.
+		if marker == 0xDA {
+			out = append(out, 0xFF, marker)
+			out = append(out, segment...)
+			end := scanEnd(data, i)
+			out = append(out, data[i:end]...)
+			i = end
+			continue
+		}
+
+		if marker == 0xFE || (marker >= 0xE1 && marker <= 0xEF && marker != 0xEE) {
+			continue
+		}
+
+		out = append(out, 0xFF, marker)
+		out = append(out, segment...)
+	}
+
+	return nil, ErrMalformed
+}
+
+// scanEnd Index of the marker that ends the entropy coded data starting at i, or len(data) when there is none.
+// Inside the data 0xFF is followed by a stuffed zero or a restart marker.
+func scanEnd(data []byte, i int) int {
+	for i < len(data) {
+		if data[i] != 0xFF {
+			i++
+			continue
+		}
+		next := i + 1
+		for next < len(data) && data[next] == 0xFF {
+			next++
+		}
+		if next < len(data) && (data[next] == 0x00 || (data[next] >= 0xD0 && data[next] <= 0xD7)) {
+			i = next + 1
+			continue
+		}
+		return i
+	}
+	return len(data)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks Text and EXIF chunks may carry locations, device names or software versions
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformed
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	i := len(pngSignature)
+	for i+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		// Length, type, data and CRC
+		end := i + 12 + length
+		if end > len(data) || end < i {
+			return nil, ErrMalformed
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+
+		if chunkType == "IEND" {
+			return out, nil
+		}
+	}
+
+	return nil, ErrMalformed
+}
@@ -0,0 +1,201 @@
+package policy
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// EstimateEntropy Rough, zxcvbn inspired, estimation of the bits needed to guess password. Known patterns like
+// common words, repeated characters, sequences, keyboard rows and user inputs are only worth a few bits,
+// everything else is charged at the size of the character pool in use.
+func EstimateEntropy(password string, userInputs ...string) float64 {
+
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	lower := []rune(strings.ToLower(password))
+	bruteforceBits := math.Log2(float64(charsetSize(runes)))
+	bits := 0.0
+
+	for i := 0; i < len(runes); {
+		if n, cost := longestPattern(lower, i, userInputs); n > 0 {
+			bits += cost + uppercaseBits(runes[i:i+n])
+			i += n
+			continue
+		}
+		bits += bruteforceBits
+		i++
+	}
+
+	return bits
+}
+
+// longestPattern Length and cost of the longest known pattern starting at i, zero when there is none.
+func longestPattern(lower []rune, i int, userInputs []string) (length int, bits float64) {
+
+	rest := string(lower[i:])
+
+	for _, input := range userInputs {
+		if n := len([]rune(input)); n > length && strings.HasPrefix(rest, input) {
+			length, bits = n, 1
+		}
+	}
+
+	for rank, word := range commonWords {
+		if n := len([]rune(word)); n > length && strings.HasPrefix(rest, word) {
+			length, bits = n, math.Log2(float64(rank+2))
+		}
+	}
+
+	if n := repeatLength(lower, i); n >= 3 && n > length {
+		length, bits = n, math.Log2(float64(charsetSize(lower[i:i+1])))+math.Log2(float64(n))
+	}
+
+	if n := sequenceLength(lower, i); n >= 3 && n > length {
+		length, bits = n, math.Log2(float64(charsetSize(lower[i:i+1])))+math.Log2(float64(n))+1
+	}
+
+	if isYear(lower[i:]) && length < 4 {
+		length, bits = 4, math.Log2(140)
+	}
+
+	for _, row := range keyboardRows {
+		if n := commonPrefixLength(rest, row); n >= 4 && n > length {
+			length, bits = n, math.Log2(float64(len(keyboardRows)*len(row)))+math.Log2(float64(n))
+		}
+	}
+
+	return length, bits
+}
+
+// isYear Years between 1900 and 2039 are common suffixes
+func isYear(runes []rune) bool {
+	if len(runes) < 4 {
+		return false
+	}
+	for _, r := range runes[:4] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	century := string(runes[:2])
+	return century == "19" || (century == "20" && runes[2] <= '3')
+}
+
+func repeatLength(runes []rune, i int) int {
+	n := 1
+	for i+n < len(runes) && runes[i+n] == runes[i] {
+		n++
+	}
+	return n
+}
+
+// sequenceLength Runs like abcd, 4321 or 2468
+func sequenceLength(runes []rune, i int) int {
+	if i+1 >= len(runes) {
+		return 1
+	}
+
+	delta := runes[i+1] - runes[i]
+	if delta == 0 || delta > 2 || delta < -2 {
+		return 1
+	}
+
+	n := 2
+	for i+n < len(runes) && runes[i+n]-runes[i+n-1] == delta {
+		n++
+	}
+	return n
+}
+
+// commonPrefixLength Longest prefix of s found anywhere in row
+func commonPrefixLength(s, row string) int {
+	longest := 0
+	for start := range len(row) {
+		n := 0
+		for n < len(s) && start+n < len(row) && s[n] == row[start+n] {
+			n++
+		}
+		longest = max(longest, n)
+	}
+	return longest
+}
+
+func uppercaseBits(runes []rune) float64 {
+	upper := 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case upper == 1 && unicode.IsUpper(runes[0]), upper == len(runes):
+		return 1
+	default:
+		return float64(len(runes))
+	}
+}
+
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return max(size, 1)
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"qwertzuiopü",
+	"azertyuiop",
+}
+
+// commonWords Most common passwords and password words, ordered by frequency
+var commonWords = []string{
+	"password", "123456", "qwerty", "admin", "welcome", "letmein", "monkey", "dragon", "football", "iloveyou",
+	"sunshine", "master", "princess", "login", "abc123", "starwars", "baseball", "shadow", "superman", "michael",
+	"trustno1", "hello", "freedom", "whatever", "qazwsx", "ninja", "mustang", "jesus", "access", "batman",
+	"passw0rd", "p@ssword", "p@ssw0rd", "secret", "summer", "winter", "spring", "autumn", "love", "chirpy",
+	"chirp", "pokemon", "hunter", "ranger", "buster", "soccer", "hockey", "killer", "george", "charlie",
+	"andrew", "thomas", "jordan", "harley", "robert", "matthew", "daniel", "computer", "internet", "cookie",
+	"cheese", "pepper", "ginger", "orange", "banana", "flower", "purple", "silver", "golden", "diamond",
+	"tigger", "maggie", "ashley", "bailey", "jennifer", "jessica", "nicole", "amanda", "hannah", "samsung",
+	"google", "apple", "pass", "test", "user", "root", "guest", "default", "changeme", "blink182",
+}
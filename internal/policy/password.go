@@ -0,0 +1,141 @@
+package policy
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+type Rule string
+
+const (
+	RuleMinLength Rule = "min_length"
+	RuleMaxLength Rule = "max_length"
+	RuleEntropy   Rule = "entropy"
+	RuleUserInput Rule = "user_input"
+	RuleBreached  Rule = "breached"
+)
+
+const defaultMaxLength = 1024
+
+type Violation struct {
+	Rule    Rule   `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ViolationError Returned when a password breaks one or more rules, it lists all of them.
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e ViolationError) Error() string {
+	rules := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		rules = append(rules, string(v.Rule))
+	}
+	return "password does not meet the policy: " + strings.Join(rules, ", ")
+}
+
+// Password The zero value accepts any non empty password.
+type Password struct {
+	MinLength      int
+	MaxLength      int
+	MinEntropyBits float64
+	Breached       BreachedList
+}
+
+// Check Validates password, userInputs are values like the email that should not be part of it.
+// Returns a ViolationError listing every failed rule.
+func (p Password) Check(password string, userInputs ...string) error {
+
+	violations := make([]Violation, 0)
+	length := utf8.RuneCountInString(password)
+
+	if length == 0 || length < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("password must be at least %d characters long", max(p.MinLength, 1)),
+		})
+	}
+
+	maxLength := p.MaxLength
+	if maxLength == 0 {
+		maxLength = defaultMaxLength
+	}
+	if length > maxLength {
+		// The other rules get slow on long input, this one alone is enough to reject it
+		return ViolationError{Violations: append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("password must be at most %d characters long", maxLength),
+		})}
+	}
+
+	inputs := userInputTokens(userInputs)
+	if containsUserInput(password, inputs) {
+		violations = append(violations, Violation{
+			Rule:    RuleUserInput,
+			Message: "password must not contain your email or other personal information",
+		})
+	}
+
+	if p.MinEntropyBits > 0 {
+		if bits := EstimateEntropy(password, inputs...); bits < p.MinEntropyBits {
+			violations = append(violations, Violation{
+				Rule:    RuleEntropy,
+				Message: "password is too easy to guess, try a longer one or a few unrelated words",
+			})
+		}
+	}
+
+	if p.Breached != nil && length > 0 {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			// Not being able to read the list should not block every signup
+			log.Printf("Could not check breached password list: %q", err)
+		} else if breached {
+			violations = append(violations, Violation{
+				Rule:    RuleBreached,
+				Message: "password appeared in a data breach, choose a different one",
+			})
+		}
+	}
+
+	if len(violations) != 0 {
+		return ViolationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// userInputTokens Splits values like emails into the parts someone might reuse in a password
+func userInputTokens(userInputs []string) []string {
+	tokens := make([]string, 0)
+	for _, input := range userInputs {
+		fields := strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return strings.ContainsRune("@._-+ ", r)
+		})
+		for _, field := range fields {
+			if len(field) >= 4 {
+				tokens = append(tokens, field)
+			}
+		}
+	}
+	return tokens
+}
+
+func containsUserInput(password string, tokens []string) bool {
+	lower := strings.ToLower(password)
+	for _, token := range tokens {
+		// Common domains like gmail or com are too short or too generic to matter
+		if len(token) >= 4 && !commonEmailWords[token] && strings.Contains(lower, token) {
+			return true
+		}
+	}
+	return false
+}
+
+var commonEmailWords = map[string]bool{
+	"gmail": true, "yahoo": true, "hotmail": true, "outlook": true, "mail": true, "chirpy": true,
+	"email": true, "icloud": true,
+}
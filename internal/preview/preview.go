@@ -0,0 +1,151 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxDocumentBytes Metadata lives in the head, there is no need to read huge pages
+const maxDocumentBytes = 512 << 10
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+var ErrNotHTML = errors.New("document is not html")
+var ErrNoMetadata = errors.New("document has no preview metadata")
+
+// Card What a link preview shows, Image is an absolute http(s) url or empty
+type Card struct {
+	Title       string
+	Description string
+	Image       string
+}
+
+// Fetch Downloads rawURL with client and reads its OpenGraph tags, falling back to the title and description tags
+func Fetch(ctx context.Context, client *http.Client, rawURL string) (Card, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Card{}, err
+	}
+	req.Header.Set("User-Agent", "Chirpy-Previews/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Card{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Card{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Card{}, ErrNotHTML
+	}
+
+	card := parse(io.LimitReader(resp.Body, maxDocumentBytes), resp.Request.URL)
+	if card.Title == "" && card.Description == "" && card.Image == "" {
+		return Card{}, ErrNoMetadata
+	}
+
+	return card, nil
+}
+
+func parse(r io.Reader, base *url.URL) Card {
+
+	var og, fallback Card
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return merge(og, fallback, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				key, content := metaAttrs(token)
+				switch key {
+				case "og:title":
+					og.Title = content
+				case "og:description":
+					og.Description = content
+				case "og:image", "og:image:url":
+					if og.Image == "" {
+						og.Image = content
+					}
+				case "description":
+					fallback.Description = content
+				}
+			case "body":
+				// Everything a preview needs is in the head
+				return merge(og, fallback, base)
+			}
+		case html.TextToken:
+			if inTitle && fallback.Title == "" {
+				fallback.Title = string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		}
+	}
+}
+
+func metaAttrs(token html.Token) (key, content string) {
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "property", "name":
+			key = strings.ToLower(strings.TrimSpace(attr.Val))
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+func merge(og, fallback Card, base *url.URL) Card {
+	card := Card{
+		Title:       clean(og.Title, fallback.Title, maxTitleLength),
+		Description: clean(og.Description, fallback.Description, maxDescriptionLength),
+	}
+
+	if og.Image != "" {
+		image, err := base.Parse(strings.TrimSpace(og.Image))
+		if err == nil && (image.Scheme == "http" || image.Scheme == "https") {
+			card.Image = image.String()
+		}
+	}
+
+	return card
+}
+
+// clean Prefers value over fallback, collapses whitespace and cuts it to limit runes
+func clean(value, fallback string, limit int) string {
+	if strings.TrimSpace(value) == "" {
+		value = fallback
+	}
+	value = strings.Join(strings.Fields(value), " ")
+
+	if runes := []rune(value); len(runes) > limit {
+		value = string(runes[:limit])
+	}
+	return value
+}
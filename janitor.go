@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+func (cfg *apiConfig) runJanitor(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting janitor, sweeping expired records every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Records that expired while the server was down are swept right away
+	cfg.sweep(time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping janitor: %q", ctx.Err())
+			return
+		case now := <-ticker.C:
+			cfg.sweep(now)
+		}
+	}
+}
+
+// sweep The steps do not depend on each other, a failing one is logged and the rest still run and count
+func (cfg *apiConfig) sweep(now time.Time) {
+
+	cfg.sweepExpired(now)
+	cfg.sweepDeletedUsers(now)
+	cfg.sweepSubscriptions(now)
+
+	cfg.janitorRuns.Add(1)
+}
+
+func (cfg *apiConfig) sweepExpired(now time.Time) {
+
+	stats, err := cfg.DB.PurgeExpired(now)
+	if err != nil {
+		log.Printf("Janitor could not purge expired records: %q", err)
+		return
+	}
+
+	for _, path := range stats.ExportPaths {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Janitor could not remove export archive %q: %q", path, err)
+		}
+	}
+
+	cfg.sweptRefreshTokens.Add(int64(stats.RefreshTokens))
+	cfg.sweptPasswordResetTokens.Add(int64(stats.PasswordResetTokens))
+	cfg.sweptLoginAttempts.Add(int64(stats.LoginAttempts))
+	cfg.sweptExports.Add(int64(stats.Exports))
+	cfg.sweptWebhookEvents.Add(int64(stats.WebhookEvents))
+
+	if stats.Total() > 0 {
+		log.Printf("Janitor swept %d expired refresh tokens, %d expired password reset tokens, %d stale login attempts, %d expired exports, %d old webhook events and %d old webhook deliveries",
+			stats.RefreshTokens, stats.PasswordResetTokens, stats.LoginAttempts, stats.Exports, stats.WebhookEvents, stats.WebhookDeliveries)
+	}
+}
+
+func (cfg *apiConfig) sweepDeletedUsers(now time.Time) {
+
+	userStats, err := cfg.DB.PurgeDeletedUsers(now.Add(-cfg.accountDeletionGrace), cfg.deletedChirpsPolicy)
+	if err != nil {
+		log.Printf("Janitor could not purge deleted users: %q", err)
+		return
+	}
+
+	for _, mediaId := range userStats.MediaIds {
+		if cfg.blobs == nil {
+			break
+		}
+		err := cfg.blobs.Delete(mediaId)
+		if err != nil {
+			log.Printf("Janitor could not remove media %s: %q", mediaId, err)
+		}
+	}
+
+	cfg.purgedUsers.Add(int64(userStats.Users))
+
+	if userStats.Users > 0 {
+		log.Printf("Janitor purged %d deleted users, deleting %d and anonymizing %d of their chirps",
+			userStats.Users, userStats.DeletedChirps, userStats.AnonymizedChirps)
+	}
+}
+
+func (cfg *apiConfig) sweepSubscriptions(now time.Time) {
+
+	expiredSubscriptions, err := cfg.DB.ExpireSubscriptions(now)
+	if err != nil {
+		log.Printf("Janitor could not expire subscriptions: %q", err)
+		return
+	}
+
+	cfg.expiredSubscriptions.Add(int64(len(expiredSubscriptions)))
+
+	for _, userId := range expiredSubscriptions {
+		user, err := cfg.DB.UserById(userId)
+		if err != nil {
+			continue
+		}
+		cfg.emitEvent(eventUserDowngraded, userId, SubscriptionEventData{
+			UserId:           userId,
+			Status:           user.Subscription.Status,
+			CurrentPeriodEnd: user.Subscription.CurrentPeriodEnd,
+		})
+	}
+	if len(expiredSubscriptions) > 0 {
+		log.Printf("Janitor expired %d lapsed Chirpy Red memberships", len(expiredSubscriptions))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestJanitorSweep(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	user := `{"email": "janitor@chirpy.com", "password": "sweep"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	loginW := httptest.NewRecorder()
+	loginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+	cfg.loginPostHandler(loginW, loginReq)
+
+	loginResp := LoginResponse{}
+	err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+	if err != nil {
+		t.Fatalf("Could not decode login response: %q", err)
+	}
+
+	cfg.sweep(time.Now())
+	if got := cfg.sweptRefreshTokens.Load(); got != 0 {
+		t.Errorf("Test failed (fresh token swept): got %d, want %d", got, 0)
+	}
+
+	cfg.sweep(time.Now().Add(25 * time.Hour))
+	if got := cfg.sweptRefreshTokens.Load(); got != 1 {
+		t.Errorf("Test failed (expired token not swept): got %d, want %d", got, 1)
+	}
+	if got := cfg.janitorRuns.Load(); got != 2 {
+		t.Errorf("Test failed (janitor runs): got %d, want %d", got, 2)
+	}
+
+	t.Run("Janitor Startup Sweep Test", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// The first sweep does not wait for the interval, even when the janitor is stopped right away
+		cfg.runJanitor(ctx, time.Hour)
+		if got := cfg.janitorRuns.Load(); got != 3 {
+			t.Errorf("Test failed (janitor runs): got %d, want %d", got, 3)
+		}
+	})
+
+	refreshW := httptest.NewRecorder()
+	refreshReq := httptest.NewRequest("POST", "/api/refresh", nil)
+	refreshReq.Header.Set("Authorization", "Bearer "+loginResp.RefreshToken)
+	cfg.postRefreshHandler(refreshW, refreshReq)
+
+	if refreshW.Code != 401 {
+		t.Errorf("Test failed (swept token still refreshes): got %d, want %d", refreshW.Code, 401)
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
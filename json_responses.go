@@ -2,16 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/benjamin-vq/chirpy/internal/assert"
+	"github.com/benjamin-vq/chirpy/internal/policy"
 )
 
 type errorResponse struct {
 	Error string `json:"error,omitempty"`
 }
 
+type policyErrorResponse struct {
+	Error       string             `json:"error"`
+	FailedRules []policy.Violation `json:"failed_rules"`
+}
+
 func respondWithError(w http.ResponseWriter, code int, msg string) {
 	errorResponse := errorResponse{}
 
@@ -29,6 +36,55 @@ func respondWithError(w http.ResponseWriter, code int, msg string) {
 	w.Write(bytes)
 }
 
+// respondWithPolicyError Lists every rule the password failed so clients can show them all at once
+func respondWithPolicyError(w http.ResponseWriter, violationErr policy.ViolationError) {
+	bytes, err := json.Marshal(policyErrorResponse{
+		Error:       "password does not meet the policy",
+		FailedRules: violationErr.Violations,
+	})
+
+	if err != nil {
+		log.Printf("Error mashalling policy error response: %q", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(bytes)
+}
+
+type chirpLengthErrorResponse struct {
+	Error  string `json:"error"`
+	Length int    `json:"length"`
+	Limit  int    `json:"limit"`
+}
+
+// respondWithChirpError Includes the computed length and the limit when the chirp is too long
+func respondWithChirpError(w http.ResponseWriter, chirpErr error) {
+	lengthErr := chirpLengthError{}
+	if !errors.As(chirpErr, &lengthErr) {
+		respondWithError(w, http.StatusBadRequest, chirpErr.Error())
+		return
+	}
+
+	bytes, err := json.Marshal(chirpLengthErrorResponse{
+		Error:  "chirp length exceeds limit",
+		Length: lengthErr.Length,
+		Limit:  lengthErr.Limit,
+	})
+
+	if err != nil {
+		log.Printf("Error mashalling chirp length error response: %q", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(bytes)
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload any) {
 	assert.That(code < 400, "Code should be in the 100-399 range")
 
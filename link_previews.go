@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"github.com/benjamin-vq/chirpy/internal/assert"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/preview"
+	"log"
+	"time"
+)
+
+const (
+	// linkPreviewTTL How long a fetched preview is reused before a new chirp linking to it fetches it again
+	linkPreviewTTL = 24 * time.Hour
+	// linkPreviewFailureTTL Failed urls are not retried before this, so a dead link is not fetched for every chirp
+	linkPreviewFailureTTL = 1 * time.Hour
+	linkPreviewTimeout    = 5 * time.Second
+)
+
+func (cfg *apiConfig) wakePreviewFetcher() {
+	if cfg.previewWake == nil {
+		return
+	}
+	select {
+	case cfg.previewWake <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *apiConfig) runPreviewFetcher(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting link preview fetcher, checking for pending urls every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cfg.fetchLinkPreviews(ctx, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping link preview fetcher: %q", ctx.Err())
+			return
+		case <-cfg.previewWake:
+			cfg.fetchLinkPreviews(ctx, time.Now())
+		case now := <-ticker.C:
+			cfg.fetchLinkPreviews(ctx, now)
+		}
+	}
+}
+
+// fetchLinkPreviews Fetches every pending url once and returns how many previews are now ready
+func (cfg *apiConfig) fetchLinkPreviews(ctx context.Context, now time.Time) int {
+
+	pending, err := cfg.DB.PendingLinkPreviews()
+	if err != nil {
+		log.Printf("Link preview fetcher could not load pending urls: %q", err)
+		return 0
+	}
+
+	// A default client would reach internal addresses, tests have to pick their client explicitly
+	assert.That(cfg.previewClient != nil, "Link preview client should be configured")
+
+	ready := 0
+	for _, link := range pending {
+		fetchCtx, cancel := context.WithTimeout(ctx, linkPreviewTimeout)
+		card, err := preview.Fetch(fetchCtx, cfg.previewClient, link.URL)
+		cancel()
+
+		link.FetchedAt = now.UTC()
+		if err != nil {
+			log.Printf("Could not fetch link preview of %q: %q", link.URL, err)
+			link.Status = database.PreviewFailed
+			link.Error = err.Error()
+			link.ExpiresAt = link.FetchedAt.Add(linkPreviewFailureTTL)
+		} else {
+			link = database.LinkPreview{
+				URL:         link.URL,
+				Title:       card.Title,
+				Description: card.Description,
+				ImageURL:    card.Image,
+				Status:      database.PreviewReady,
+				FetchedAt:   link.FetchedAt,
+				ExpiresAt:   link.FetchedAt.Add(linkPreviewTTL),
+			}
+			ready++
+		}
+
+		err = cfg.DB.SaveLinkPreview(link)
+		if err != nil {
+			log.Printf("Could not save link preview of %q: %q", link.URL, err)
+		}
+	}
+
+	return ready
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/preview"
+	"github.com/benjamin-vq/chirpy/internal/safehttp"
+)
+
+func TestLinkPreviews(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	site := http.NewServeMux()
+	site.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Fallback title</title>
+<meta property="og:title" content="  An   article ">
+<meta property="og:description" content="All about chirps">
+<meta property="og:image" content="/cover.png">
+</head><body><meta property="og:title" content="Ignored"></body></html>`))
+	})
+	site.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Just a title</title><meta name="description" content="Described"></head></html>`))
+	})
+	site.HandleFunc("/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	server := httptest.NewServer(site)
+	defer server.Close()
+
+	cfg := apiConfig{
+		DB:            db,
+		jwtSecret:     "dGVzdA==",
+		previewClient: server.Client(),
+	}
+
+	user := `{"email": "linker@chirpy.com", "password": "tangerine-lantern-42"}`
+	createW := httptest.NewRecorder()
+	cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+	loginW := httptest.NewRecorder()
+	cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+	loginResp := LoginResponse{}
+	err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+	if err != nil {
+		t.Fatalf("Could not decode login response: %q", err)
+	}
+
+	body := fmt.Sprintf("¡Mira! %s/article. Also %s/plain and %s/data.json", server.URL, server.URL, server.URL)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(fmt.Sprintf(`{"body": %q}`, body)))
+	req.Header.Set("Authorization", "Bearer "+loginResp.Token)
+	cfg.postChirpHandler(w, req)
+	if w.Code != 201 {
+		t.Fatalf("Test failed (code): got %d, want %d", w.Code, 201)
+	}
+
+	chirp := database.Chirp{}
+	_ = json.NewDecoder(w.Body).Decode(&chirp)
+
+	t.Run("Link Entities Test", func(t *testing.T) {
+		if len(chirp.Entities) != 3 {
+			t.Fatalf("Test failed (entities): got %d, want %d", len(chirp.Entities), 3)
+		}
+		first := chirp.Entities[0]
+		wantURL := server.URL + "/article"
+		if first.URL != wantURL || first.Start != 7 || first.End != 7+len(wantURL) {
+			t.Errorf("Test failed (entity): got %+v, want %q at [7, %d)", first, wantURL, 7+len(wantURL))
+		}
+		if got := string([]rune(chirp.Body)[first.Start:first.End]); got != wantURL {
+			t.Errorf("Test failed (offsets): got %q, want %q", got, wantURL)
+		}
+		if len(chirp.Previews) != 0 {
+			t.Errorf("Test failed (previews before fetching): got %d, want %d", len(chirp.Previews), 0)
+		}
+	})
+
+	t.Run("Link Preview Fetch Test", func(t *testing.T) {
+		if got := cfg.fetchLinkPreviews(context.Background(), time.Now()); got != 2 {
+			t.Errorf("Test failed (ready): got %d, want %d", got, 2)
+		}
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps/1", nil)
+		req.SetPathValue("chirpId", "1")
+		cfg.chirpIdGetHandler(w, req)
+
+		fetched := database.Chirp{}
+		_ = json.NewDecoder(w.Body).Decode(&fetched)
+		if len(fetched.Previews) != 2 {
+			t.Fatalf("Test failed (previews): got %d, want %d", len(fetched.Previews), 2)
+		}
+
+		article := fetched.Previews[0]
+		if article.Title != "An article" || article.Description != "All about chirps" || article.ImageURL != server.URL+"/cover.png" {
+			t.Errorf("Test failed (opengraph preview): got %+v", article)
+		}
+		plain := fetched.Previews[1]
+		if plain.Title != "Just a title" || plain.Description != "Described" || plain.ImageURL != "" {
+			t.Errorf("Test failed (fallback preview): got %+v", plain)
+		}
+
+		// Nothing is pending anymore, previews are cached
+		if got := cfg.fetchLinkPreviews(context.Background(), time.Now()); got != 0 {
+			t.Errorf("Test failed (cached): got %d, want %d", got, 0)
+		}
+	})
+
+	t.Run("Link Preview Private Address Test", func(t *testing.T) {
+		_, err := preview.Fetch(context.Background(), safehttp.NewClient(time.Second), server.URL+"/article")
+		if !errors.Is(err, safehttp.ErrBlockedAddress) {
+			t.Errorf("Test failed (ssrf): got %v, want %q", err, safehttp.ErrBlockedAddress)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
+
+func TestBlockedAddress(t *testing.T) {
+
+	cases := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "192.168.1.1", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.64.0.1", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "::1", want: true},
+		{addr: "fd00::1", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "93.184.216.34", want: false},
+		{addr: "2606:4700::1111", want: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Blocked Address Test Case %d", i), func(t *testing.T) {
+			if got := safehttp.Blocked(netip.MustParseAddr(c.addr)); got != c.want {
+				t.Errorf("Test failed (%s): got %t, want %t", c.addr, got, c.want)
+			}
+		})
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"github.com/benjamin-vq/chirpy/internal/database"
 	"log"
 	"net/http"
+	"time"
 )
 
+const twoFactorChallengeTTL = 5 * time.Minute
+
 type LoginParams struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -20,6 +23,11 @@ type LoginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
 func (cfg *apiConfig) loginPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	loginParams := LoginParams{}
@@ -32,11 +40,17 @@ func (cfg *apiConfig) loginPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	accountKey, ipKey := accountThrottleKey(loginParams.Email), ipThrottleKey(r)
+	if !cfg.checkLoginThrottle(w, accountKey, ipKey) {
+		return
+	}
+
 	user, err := cfg.DB.UserByEmail(loginParams.Email)
 
 	if err != nil {
 		if errors.Is(err, database.UserNotExists) {
 			log.Printf("User with email %q does not exist %q", loginParams.Email, err)
+			cfg.recordLoginFailure(accountKey, ipKey)
 			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 			return
 		}
@@ -48,10 +62,72 @@ func (cfg *apiConfig) loginPostHandler(w http.ResponseWriter, r *http.Request) {
 	err = auth.ComparePasswordHash(user.HashedPassword, loginParams.Password)
 	if err != nil {
 		log.Printf("Received password does not match")
+		cfg.recordLoginFailure(accountKey, ipKey)
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
+	// Only chance to upgrade the stored hash, the plain password is not available anywhere else
+	if auth.NeedsRehash(user.HashedPassword) {
+		cfg.rehashPassword(user.Id, user.HashedPassword, loginParams.Password)
+	}
+
+	// With two-factor enabled the account stays throttled until the second step succeeds
+	if user.TwoFactorEnabled {
+		challenge, err := auth.CreateChallengeToken(user.Id, cfg.jwtSecret, twoFactorChallengeTTL)
+		if err != nil {
+			log.Printf("Could not create two-factor challenge: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not login")
+			return
+		}
+
+		log.Printf("User with id %d needs to complete two-factor authentication", user.Id)
+		respondWithJSON(w, http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
+		return
+	}
+
+	cfg.resetLoginFailures(accountKey)
+	cfg.respondWithSession(w, user)
+}
+
+// rehashPassword Failing to upgrade the hash is not a reason to fail the login, it will be retried on the next one.
+func (cfg *apiConfig) rehashPassword(userId int, verifiedHash, password string) {
+
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Could not rehash password of user with id %d: %q", userId, err)
+		return
+	}
+
+	err = cfg.DB.UpdatePasswordHash(userId, verifiedHash, hashed)
+	if errors.Is(err, database.ErrPasswordChanged) {
+		log.Printf("Password of user with id %d changed during login, not rehashing it", userId)
+		return
+	}
+	if err != nil {
+		log.Printf("Could not save rehashed password of user with id %d: %q", userId, err)
+		return
+	}
+
+	log.Printf("Upgraded password hash of user with id %d", userId)
+}
+
+// respondWithSession Issues an access and a refresh token for an user that is fully authenticated.
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, user database.User) {
+
+	// Logging in during the grace period cancels a pending account deletion
+	if user.Deleted() {
+		err := cfg.DB.RestoreUser(user.Id)
+		if err != nil {
+			log.Printf("Could not restore deleted user with id %d: %q", user.Id, err)
+			respondWithError(w, http.StatusInternalServerError, "Could not login")
+			return
+		}
+	}
+
 	jwt, err := auth.CreateJwt(user.Id, cfg.jwtSecret)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not login")
@@ -73,11 +149,7 @@ func (cfg *apiConfig) loginPostHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondWithJSON(w, http.StatusOK, LoginResponse{
-		User: User{
-			user.Email,
-			user.Id,
-			user.IsChirpyRed,
-		},
+		User:         newUser(user),
 		Token:        jwt,
 		RefreshToken: rt,
 	})
@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
+	"golang.org/x/crypto/bcrypt"
 	"io"
 	"log"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoginPostHandler(t *testing.T) {
@@ -118,3 +121,160 @@ func TestLoginPostHandler(t *testing.T) {
 		log.Fatalf("Could not cleanup database file: %q", err)
 	}
 }
+
+func TestLoginPostHandlerThrottle(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+		loginThrottle: loginThrottle{
+			maxAccountFailures: 3,
+			maxIPFailures:      5,
+			baseLockout:        time.Minute,
+			maxLockout:         time.Hour,
+		},
+	}
+
+	user := `{"email": "throttled@chirpy.com", "password": "correct-password"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	login := func(request, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(request))
+		req.RemoteAddr = remoteAddr
+		cfg.loginPostHandler(w, req)
+		return w
+	}
+
+	wrongPassword := `{"email": "throttled@chirpy.com", "password": "guess"}`
+	cases := []struct {
+		request        string
+		remoteAddr     string
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{request: wrongPassword, remoteAddr: "10.0.0.1:1234", wantCode: 401},
+		{request: wrongPassword, remoteAddr: "10.0.0.1:1234", wantCode: 401},
+		{request: user, remoteAddr: "10.0.0.1:1234", wantCode: 200},
+		{request: wrongPassword, remoteAddr: "10.0.0.1:1234", wantCode: 401},
+		{request: wrongPassword, remoteAddr: "10.0.0.2:1234", wantCode: 401},
+		{request: wrongPassword, remoteAddr: "10.0.0.2:1234", wantCode: 401},
+		// Account locked after 3 consecutive failures, even with the right password or from another address
+		{request: user, remoteAddr: "10.0.0.3:1234", wantCode: 429, wantRetryAfter: "60"},
+		// Address locked after 5 failures, for any account
+		{request: `{"email": "other@chirpy.com", "password": "guess"}`, remoteAddr: "10.0.0.1:1234", wantCode: 401},
+		{request: `{"email": "other@chirpy.com", "password": "guess"}`, remoteAddr: "10.0.0.1:1234", wantCode: 401},
+		{request: `{"email": "another@chirpy.com", "password": "guess"}`, remoteAddr: "10.0.0.1:1234", wantCode: 429, wantRetryAfter: "60"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Login Post Handler Throttle Test Case %d", i), func(t *testing.T) {
+			w := login(c.request, c.remoteAddr)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+			if got := w.Header().Get("Retry-After"); got != c.wantRetryAfter {
+				t.Errorf("Test failed (Retry-After): got %q, want %q", got, c.wantRetryAfter)
+			}
+		})
+	}
+
+	// Lockout state lives in the database, a new instance still sees it
+	restarted := apiConfig{DB: db, jwtSecret: cfg.jwtSecret, loginThrottle: cfg.loginThrottle}
+	w := httptest.NewRecorder()
+	restarted.loginPostHandler(w, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+	if w.Code != 429 {
+		t.Errorf("Test failed (lockout after restart): got %d, want %d", w.Code, 429)
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Minute},
+		{attempt: 1, want: 2 * time.Minute},
+		{attempt: 3, want: 8 * time.Minute},
+		{attempt: 10, want: time.Hour},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Backoff Test Case %d", i), func(t *testing.T) {
+			if got := backoff(c.attempt, time.Minute, time.Hour); got != c.want {
+				t.Errorf("Test failed: got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoginPostHandlerRehash(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	legacyHash, err := auth.BcryptHasher{Cost: bcrypt.MinCost}.Hash("legacy-password")
+	if err != nil {
+		t.Fatalf("Could not hash legacy password: %q", err)
+	}
+	user, err := db.CreateUser("legacy@chirpy.com", legacyHash)
+	if err != nil {
+		t.Fatalf("Could not create legacy user: %q", err)
+	}
+
+	request := `{"email": "legacy@chirpy.com", "password": "legacy-password"}`
+	for i := range 2 {
+		t.Run(fmt.Sprintf("Login Post Handler Rehash Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/login", strings.NewReader(request))
+
+			cfg.loginPostHandler(w, req)
+
+			if w.Code != 200 {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, 200)
+			}
+
+			rehashed, _ := db.UserById(user.Id)
+			if !strings.HasPrefix(rehashed.HashedPassword, "$argon2id$") {
+				t.Errorf("Test failed, expected password to be rehashed with argon2id, got %.10s", rehashed.HashedPassword)
+			}
+		})
+	}
+
+	// A rehash racing with a password change must not restore the old password
+	err = db.UpdatePasswordHash(user.Id, legacyHash, "$argon2id$stale")
+	if !errors.Is(err, database.ErrPasswordChanged) {
+		t.Errorf("Test failed (stale rehash): got %v, want %v", err, database.ErrPasswordChanged)
+	}
+	if stored, _ := db.UserById(user.Id); stored.HashedPassword == "$argon2id$stale" {
+		t.Errorf("Test failed, stale rehash overwrote the stored password")
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loginThrottle Limits password guesses per account and per client address. The zero value disables it.
+type loginThrottle struct {
+	maxAccountFailures int
+	maxIPFailures      int
+	baseLockout        time.Duration
+	maxLockout         time.Duration
+}
+
+func (t loginThrottle) enabled() bool {
+	return t.maxAccountFailures > 0 || t.maxIPFailures > 0
+}
+
+// lockout Locks once failures reach threshold, doubling the lockout with every further failure.
+func (t loginThrottle) lockout(threshold int) func(failures int) time.Duration {
+	return func(failures int) time.Duration {
+		if threshold <= 0 || failures < threshold {
+			return 0
+		}
+		return backoff(failures-threshold, t.baseLockout, t.maxLockout)
+	}
+}
+
+// backoff Exponential delay for the given attempt, starting at base and capped at limit.
+func backoff(attempt int, base, limit time.Duration) time.Duration {
+	delay := float64(base) * math.Pow(2, float64(attempt))
+	if delay > float64(limit) {
+		return limit
+	}
+	return time.Duration(delay)
+}
+
+func accountThrottleKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipThrottleKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// checkLoginThrottle Responds with 429 and returns false when any of the keys is locked.
+func (cfg *apiConfig) checkLoginThrottle(w http.ResponseWriter, keys ...string) bool {
+	if !cfg.loginThrottle.enabled() {
+		return true
+	}
+
+	lockedUntil, err := cfg.DB.LoginLockedUntil(keys...)
+	if err != nil {
+		log.Printf("Could not check login lockout: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not login")
+		return false
+	}
+
+	retryAfter := time.Until(lockedUntil)
+	if retryAfter <= 0 {
+		return true
+	}
+
+	log.Printf("Rejecting login for %v, locked for another %v", keys, retryAfter)
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+	return false
+}
+
+func (cfg *apiConfig) recordLoginFailure(accountKey, ipKey string) {
+	if !cfg.loginThrottle.enabled() {
+		return
+	}
+
+	now := time.Now()
+	_, err := cfg.DB.RecordLoginFailure(accountKey, now, cfg.loginThrottle.lockout(cfg.loginThrottle.maxAccountFailures))
+	if err != nil {
+		log.Printf("Could not record login failure for %q: %q", accountKey, err)
+	}
+
+	if ipKey == "" {
+		return
+	}
+	_, err = cfg.DB.RecordLoginFailure(ipKey, now, cfg.loginThrottle.lockout(cfg.loginThrottle.maxIPFailures))
+	if err != nil {
+		log.Printf("Could not record login failure for %q: %q", ipKey, err)
+	}
+}
+
+func (cfg *apiConfig) resetLoginFailures(accountKey string) {
+	if !cfg.loginThrottle.enabled() {
+		return
+	}
+
+	err := cfg.DB.ResetLoginFailures(accountKey)
+	if err != nil {
+		log.Printf("Could not reset login failures for %q: %q", accountKey, err)
+	}
+}
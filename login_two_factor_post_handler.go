@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+func (cfg *apiConfig) loginTwoFactorPostHandler(w http.ResponseWriter, r *http.Request) {
+
+	type twoFactorParams struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	params := twoFactorParams{}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding two-factor login parameters: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not decode login parameters")
+		return
+	}
+
+	userId, err := auth.UserIdFromChallengeToken(params.ChallengeToken, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	user, err := cfg.DB.UserById(userId)
+	if err != nil || !user.TwoFactorEnabled {
+		log.Printf("Two-factor challenge for user with id %d can not be completed: %v", userId, err)
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge")
+		return
+	}
+
+	accountKey, ipKey := accountThrottleKey(user.Email), ipThrottleKey(r)
+	if !cfg.checkLoginThrottle(w, accountKey, ipKey) {
+		return
+	}
+
+	switch {
+	case params.Code != "":
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, params.Code, time.Now())
+		if !ok {
+			log.Printf("Received an invalid totp code for user with id %d", userId)
+			cfg.recordLoginFailure(accountKey, ipKey)
+			respondWithError(w, http.StatusUnauthorized, "Invalid code")
+			return
+		}
+		err = cfg.DB.UseTOTPStep(userId, step)
+	case params.RecoveryCode != "":
+		codeHash := auth.HashOpaqueToken(auth.NormalizeRecoveryCode(params.RecoveryCode))
+		err = cfg.DB.UseRecoveryCode(userId, codeHash)
+	default:
+		respondWithError(w, http.StatusBadRequest, "code or recovery_code is required")
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, database.ErrTOTPCodeReused) || errors.Is(err, database.ErrInvalidRecoveryCode) {
+			cfg.recordLoginFailure(accountKey, ipKey)
+			respondWithError(w, http.StatusUnauthorized, "Invalid code")
+			return
+		}
+		log.Printf("Could not complete two-factor login: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not login")
+		return
+	}
+
+	cfg.resetLoginFailures(accountKey)
+	cfg.respondWithSession(w, user)
+}
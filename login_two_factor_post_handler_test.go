@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestLoginTwoFactorPostHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	user := `{"email": "twofactor@chirpy.com", "password": "second-factor"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	login := func() map[string]any {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(w, req)
+
+		resp := map[string]any{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		return resp
+	}
+
+	token, _ := login()["token"].(string)
+
+	setupW := httptest.NewRecorder()
+	setupReq := httptest.NewRequest("POST", "/api/users/2fa/setup", nil)
+	setupReq.Header.Set("Authorization", "Bearer "+token)
+	cfg.postTwoFactorSetupHandler(setupW, setupReq)
+
+	setupResp := map[string]string{}
+	err = json.NewDecoder(setupW.Body).Decode(&setupResp)
+	if err != nil {
+		t.Fatalf("Could not decode setup response: %q", err)
+	}
+	secret := setupResp["secret"]
+	if !strings.HasPrefix(setupResp["otpauth_uri"], "otpauth://totp/Chirpy:twofactor@chirpy.com?") {
+		t.Errorf("Test failed (otpauth uri): got %s", setupResp["otpauth_uri"])
+	}
+
+	confirm := func(code string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/users/2fa/confirm", strings.NewReader(fmt.Sprintf(`{"code": %q}`, code)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.postTwoFactorConfirmHandler(w, req)
+		return w
+	}
+
+	if w := confirm("000000x"); w.Code != 400 {
+		t.Errorf("Test failed (confirm with wrong code): got %d, want %d", w.Code, 400)
+	}
+
+	code, _ := auth.TOTPCode(secret, time.Now())
+	confirmW := confirm(code)
+	if confirmW.Code != 200 {
+		t.Fatalf("Test failed (confirm): got %d, want %d", confirmW.Code, 200)
+	}
+
+	confirmResp := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{}
+	err = json.NewDecoder(confirmW.Body).Decode(&confirmResp)
+	if err != nil || len(confirmResp.RecoveryCodes) != recoveryCodesCount {
+		t.Fatalf("Test failed, expected %d recovery codes: %v", recoveryCodesCount, err)
+	}
+
+	loginResp := login()
+	if _, ok := loginResp["token"]; ok {
+		t.Fatalf("Test failed, login should not issue tokens before the second factor")
+	}
+	challenge, _ := loginResp["challenge_token"].(string)
+
+	// Already used to confirm the setup
+	reusedCode := code
+	nextCode, _ := auth.TOTPCode(secret, time.Now().Add(30*time.Second))
+
+	cases := []struct {
+		request  string
+		wantCode int
+	}{
+		{
+			request:  fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, token, nextCode),
+			wantCode: 401,
+		},
+		{
+			request:  fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, challenge, reusedCode),
+			wantCode: 401,
+		},
+		{
+			request:  fmt.Sprintf(`{"challenge_token": %q}`, challenge),
+			wantCode: 400,
+		},
+		{
+			request:  fmt.Sprintf(`{"challenge_token": %q, "code": %q}`, challenge, nextCode),
+			wantCode: 200,
+		},
+		{
+			request:  fmt.Sprintf(`{"challenge_token": %q, "recovery_code": %q}`, challenge, strings.ToUpper(confirmResp.RecoveryCodes[0])),
+			wantCode: 200,
+		},
+		{
+			request:  fmt.Sprintf(`{"challenge_token": %q, "recovery_code": %q}`, challenge, confirmResp.RecoveryCodes[0]),
+			wantCode: 401,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Login Two Factor Post Handler Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/login/2fa", strings.NewReader(c.request))
+
+			cfg.loginTwoFactorPostHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+
+			resp := map[string]any{}
+			json.NewDecoder(w.Body).Decode(&resp)
+			if _, ok := resp["token"]; !ok && w.Code == 200 {
+				t.Errorf("Test failed, expected response to have a 'token' field")
+			}
+		})
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
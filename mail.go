@@ -0,0 +1,12 @@
+package main
+
+import "log"
+
+func (cfg *apiConfig) sendMail(to, subject, body string) error {
+	if cfg.mailer == nil {
+		log.Printf("No mailer configured, dropping email %q to %q", subject, to)
+		return nil
+	}
+
+	return cfg.mailer.Send(to, subject, body)
+}
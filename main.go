@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"github.com/benjamin-vq/chirpy/internal/assert"
 	"github.com/joho/godotenv"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/mailer"
+	"github.com/benjamin-vq/chirpy/internal/media"
+	"github.com/benjamin-vq/chirpy/internal/policy"
+	"github.com/benjamin-vq/chirpy/internal/safehttp"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -19,20 +34,70 @@ const (
 
 	dbFilename = "database.json"
 
-	fsPath            = "/app/*"
-	readinessPath     = "GET /api/healthz"
-	metricsPath       = "GET /admin/metrics"
-	resetMetricsPath  = "GET /api/reset"
-	postChirpPath     = "POST /api/chirps"
-	getChirpsPath     = "GET /api/chirps"
-	getChirpIdPath    = "GET /api/chirps/{chirpId}"
-	postUsersPath     = "POST /api/users"
-	loginPath         = "POST /api/login"
-	putUsersPath      = "PUT /api/users"
-	postRefreshPath   = "POST /api/refresh"
-	postRevokePath    = "POST /api/revoke"
-	deleteChirpIdPath = "DELETE /api/chirps/{chirpId}"
-	postPolkaPath     = "POST /api/polka/webhooks"
+	defaultJanitorInterval = 1 * time.Hour
+	shutdownTimeout        = 10 * time.Second
+
+	fsPath                   = "/app/*"
+	readinessPath            = "GET /api/healthz"
+	metricsPath              = "GET /admin/metrics"
+	resetMetricsPath         = "GET /api/reset"
+	postChirpPath            = "POST /api/chirps"
+	getChirpsPath            = "GET /api/chirps"
+	getChirpIdPath           = "GET /api/chirps/{chirpId}"
+	postUsersPath            = "POST /api/users"
+	loginPath                = "POST /api/login"
+	putUsersPath             = "PUT /api/users"
+	postRefreshPath          = "POST /api/refresh"
+	postRevokePath           = "POST /api/revoke"
+	deleteChirpIdPath        = "DELETE /api/chirps/{chirpId}"
+	putChirpIdPath           = "PUT /api/chirps/{chirpId}"
+	getScheduledPath         = "GET /api/chirps/scheduled"
+	putScheduledPath         = "PUT /api/chirps/scheduled/{chirpId}"
+	deleteScheduledPath      = "DELETE /api/chirps/scheduled/{chirpId}"
+	postDraftsPath           = "POST /api/drafts"
+	getDraftsPath            = "GET /api/drafts"
+	putDraftPath             = "PUT /api/drafts/{draftId}"
+	deleteDraftPath          = "DELETE /api/drafts/{draftId}"
+	postDraftPublishPath     = "POST /api/drafts/{draftId}/publish"
+	postPolkaPath            = "POST /api/polka/webhooks"
+	passwordForgotPath       = "POST /api/password/forgot"
+	passwordResetPath        = "POST /api/password/reset"
+	verifyEmailPath          = "GET /api/users/verify"
+	twoFactorSetupPath       = "POST /api/users/2fa/setup"
+	twoFactorConfirmPath     = "POST /api/users/2fa/confirm"
+	loginTwoFactorPath       = "POST /api/login/2fa"
+	patchUsersMePath         = "PATCH /api/users/me"
+	deleteUsersMePath        = "DELETE /api/users/me"
+	postExportPath           = "POST /api/users/me/export"
+	getExportPath            = "GET /api/users/me/export/{exportId}"
+	postMediaPath            = "POST /api/media"
+	getMediaPath             = "GET /media/{mediaId}"
+	adminWebhooksPath        = "GET /admin/webhooks"
+	adminReplayPath          = "POST /admin/webhooks/{eventId}/replay"
+	postWebhooksPath         = "POST /api/webhooks"
+	getWebhooksPath          = "GET /api/webhooks"
+	deleteWebhookPath        = "DELETE /api/webhooks/{endpointId}"
+	enableWebhookPath        = "POST /api/webhooks/{endpointId}/enable"
+	getDeliveriesPath        = "GET /api/webhooks/{endpointId}/deliveries"
+	getUserPath              = "GET /api/users/{userId}"
+	getUserByHandlePath      = "GET /api/users/by-handle/{handle}"
+	postFollowPath           = "POST /api/users/{userId}/follow"
+	deleteFollowPath         = "DELETE /api/users/{userId}/follow"
+	postBlockPath            = "POST /api/users/{userId}/block"
+	deleteBlockPath          = "DELETE /api/users/{userId}/block"
+	postMutePath             = "POST /api/users/{userId}/mute"
+	deleteMutePath           = "DELETE /api/users/{userId}/mute"
+	getBlocksPath            = "GET /api/users/me/blocks"
+	getMutesPath             = "GET /api/users/me/mutes"
+	postConversationsPath    = "POST /api/conversations"
+	getConversationsPath     = "GET /api/conversations"
+	postMessagesPath         = "POST /api/conversations/{conversationId}/messages"
+	getMessagesPath          = "GET /api/conversations/{conversationId}/messages"
+	postConversationReadPath = "POST /api/conversations/{conversationId}/read"
+	postBookmarkPath         = "POST /api/chirps/{chirpId}/bookmark"
+	// deleteChirpActionPath Serves DELETE /api/chirps/{chirpId}/bookmark, that pattern would conflict with deleteScheduledPath
+	deleteChirpActionPath = "DELETE /api/chirps/{chirpId}/{action}"
+	getBookmarksPath      = "GET /api/bookmarks"
 )
 
 var debug = flag.Bool("debug", false, "Start on debug mode")
@@ -42,6 +107,52 @@ type apiConfig struct {
 	DB             *database.DB
 	jwtSecret      string
 	polkaApiKey    string
+	mailer         mailer.Mailer
+	baseURL        string
+
+	requireVerifiedEmail bool
+	loginThrottle        loginThrottle
+	passwordPolicy       policy.Password
+	accountDeletionGrace time.Duration
+	deletedChirpsPolicy  database.ChirpPolicy
+	exportDir            string
+	exportTTL            time.Duration
+	blobs                media.BlobStore
+	mediaLimits          media.Limits
+	// polkaWebhookSecret Polka webhooks are rejected when empty
+	polkaWebhookSecret      string
+	polkaSignatureTolerance time.Duration
+	webhookRetry            webhookRetry
+	webhookWake             chan struct{}
+	// adminApiKey Admin endpoints are disabled when empty
+	adminApiKey   string
+	outboundRetry webhookRetry
+	// endpointDisableAfter Consecutive failed deliveries before an endpoint is disabled, never when zero
+	endpointDisableAfter int
+	// webhookClient Delivers outbound webhooks, it has to refuse internal addresses outside of tests
+	webhookClient *http.Client
+	// allowInternalWebhooks Lets tests register endpoints on a local server
+	allowInternalWebhooks bool
+	dispatchWake          chan struct{}
+	// plans Entitlements of every plan, defaultPlans when nil
+	plans        map[plan]entitlements
+	chirpLimiter chirpRateLimiter
+	// previewClient Fetches link previews, it has to refuse internal addresses outside of tests
+	previewClient *http.Client
+	previewWake   chan struct{}
+	schedulerWake chan struct{}
+	exportWake    chan struct{}
+
+	janitorRuns              atomic.Int64
+	sweptRefreshTokens       atomic.Int64
+	sweptPasswordResetTokens atomic.Int64
+	sweptLoginAttempts       atomic.Int64
+	purgedUsers              atomic.Int64
+	sweptExports             atomic.Int64
+	sweptWebhookEvents       atomic.Int64
+	expiredSubscriptions     atomic.Int64
+	deadWebhookEvents        atomic.Int64
+	disabledEndpoints        atomic.Int64
 }
 
 func setupFlags() {
@@ -57,6 +168,149 @@ func setupFlags() {
 	err := godotenv.Load()
 	assert.NoError(err, "Could not load environment variables")
 }
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	assert.NoError(err, "Invalid duration for %s: %q", key, err)
+	assert.That(duration > 0, "Duration for %s should be positive", key)
+
+	return duration
+}
+
+func intFromEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	assert.NoError(err, "Invalid integer for %s: %q", key, err)
+
+	return parsed
+}
+
+func boolFromEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	assert.NoError(err, "Invalid boolean for %s: %q", key, err)
+
+	return parsed
+}
+
+func passwordHasherFromEnv() auth.Hasher {
+	switch algorithm := os.Getenv("PASSWORD_HASHER"); algorithm {
+	case "", "argon2id":
+		defaults := auth.DefaultArgon2idHasher()
+		memory := intFromEnv("ARGON2_MEMORY_KIB", int(defaults.Memory))
+		iterations := intFromEnv("ARGON2_ITERATIONS", int(defaults.Iterations))
+		parallelism := intFromEnv("ARGON2_PARALLELISM", int(defaults.Parallelism))
+		// Checked before the conversions below, which would silently wrap negative or huge values
+		assert.That(memory > 0 && uint64(memory) <= math.MaxUint32, "ARGON2_MEMORY_KIB should be a positive number of KiB")
+		assert.That(iterations > 0 && uint64(iterations) <= math.MaxUint32, "ARGON2_ITERATIONS should be positive")
+		assert.That(parallelism > 0 && parallelism < 256, "ARGON2_PARALLELISM should be between 1 and 255")
+
+		hasher := auth.Argon2idHasher{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+			SaltLength:  defaults.SaltLength,
+			KeyLength:   defaults.KeyLength,
+		}
+		err := hasher.Validate()
+		assert.NoError(err, "Invalid argon2id parameters: %q", err)
+		return hasher
+	case "bcrypt":
+		cost := intFromEnv("BCRYPT_COST", 0)
+		assert.That(cost == 0 || (cost >= bcrypt.MinCost && cost <= bcrypt.MaxCost), "BCRYPT_COST should be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		return auth.BcryptHasher{Cost: cost}
+	default:
+		log.Panicf("Unknown PASSWORD_HASHER %q, should be argon2id or bcrypt", algorithm)
+		return nil
+	}
+}
+
+func passwordPolicyFromEnv() policy.Password {
+	passwordPolicy := policy.Password{
+		MinLength:      intFromEnv("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:      intFromEnv("PASSWORD_MAX_LENGTH", 1024),
+		MinEntropyBits: float64(intFromEnv("PASSWORD_MIN_ENTROPY_BITS", 40)),
+	}
+
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		passwordPolicy.Breached = policy.BreachedDir{Path: dir}
+	} else {
+		log.Printf("BREACHED_PASSWORDS_DIR is not set, passwords will not be checked against breaches")
+	}
+
+	return passwordPolicy
+}
+
+func deletedChirpsPolicyFromEnv() database.ChirpPolicy {
+	switch chirpPolicy := database.ChirpPolicy(os.Getenv("DELETED_USER_CHIRPS")); chirpPolicy {
+	case "":
+		return database.AnonymizeChirps
+	case database.AnonymizeChirps, database.DeleteChirps:
+		return chirpPolicy
+	default:
+		log.Panicf("Unknown DELETED_USER_CHIRPS %q, should be anonymize or delete", chirpPolicy)
+		return ""
+	}
+}
+
+func plansFromEnv() map[plan]entitlements {
+	free, red := defaultPlans[planFree], defaultPlans[planRed]
+
+	free.MaxChirpLength = intFromEnv("FREE_MAX_CHIRP_LENGTH", free.MaxChirpLength)
+	free.ChirpsPerHour = intFromEnv("FREE_CHIRPS_PER_HOUR", free.ChirpsPerHour)
+	red.MaxChirpLength = intFromEnv("RED_MAX_CHIRP_LENGTH", red.MaxChirpLength)
+	red.ChirpsPerHour = intFromEnv("RED_CHIRPS_PER_HOUR", red.ChirpsPerHour)
+
+	return map[plan]entitlements{
+		planFree: free,
+		planRed:  red,
+	}
+}
+
+func blobStoreFromEnv() media.BlobStore {
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	return media.DiskStore{Dir: dir}
+}
+
+func mailerFromEnv() mailer.Mailer {
+	smtpHost := os.Getenv("SMTP_HOST")
+	if smtpHost == "" {
+		log.Printf("SMTP_HOST is not set, emails will be logged instead of sent")
+		return mailer.LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+	}
+
+	smtpPort := os.Getenv("SMTP_PORT")
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+	from := os.Getenv("MAIL_FROM")
+	assert.That(from != "", "MAIL_FROM should not be empty when sending emails through SMTP")
+
+	return mailer.SMTPMailer{
+		Host:     smtpHost,
+		Port:     smtpPort,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
 func main() {
 	setupFlags()
 
@@ -65,8 +319,29 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error creating database: %q", err)
 	}
+	migrated, err := db.MigrateLegacySubscriptions(time.Now(), time.Now().Add(defaultSubscriptionPeriod))
+	if err != nil {
+		log.Fatalf("Error migrating legacy subscriptions: %q", err)
+	}
+	if migrated > 0 {
+		log.Printf("Gave %d Chirpy Red members without a subscription one that lasts %v", migrated, defaultSubscriptionPeriod)
+	}
 	jwtSecret := os.Getenv("JWT_SECRET")
 	polkaApiKey := os.Getenv("POLKA_API_KEY")
+	polkaWebhookSecret := os.Getenv("POLKA_WEBHOOK_SECRET")
+	if polkaWebhookSecret == "" {
+		log.Printf("POLKA_WEBHOOK_SECRET is not set, polka webhooks will be rejected")
+	}
+	auth.SetPasswordHasher(passwordHasherFromEnv())
+	exportDir := os.Getenv("EXPORT_DIR")
+	if exportDir == "" {
+		exportDir = "exports"
+	}
+	janitorInterval := durationFromEnv("JANITOR_INTERVAL", defaultJanitorInterval)
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost" + port
+	}
 	assert.That(jwtSecret != "", "Jwt Secret should not be empty")
 
 	apiConfig := apiConfig{
@@ -74,6 +349,49 @@ func main() {
 		DB:             db,
 		jwtSecret:      jwtSecret,
 		polkaApiKey:    polkaApiKey,
+		mailer:         mailerFromEnv(),
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+
+		requireVerifiedEmail: boolFromEnv("REQUIRE_EMAIL_VERIFICATION", false),
+		loginThrottle: loginThrottle{
+			maxAccountFailures: intFromEnv("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+			maxIPFailures:      intFromEnv("LOGIN_MAX_IP_FAILURES", 20),
+			baseLockout:        durationFromEnv("LOGIN_LOCKOUT", 1*time.Minute),
+			maxLockout:         durationFromEnv("LOGIN_MAX_LOCKOUT", 1*time.Hour),
+		},
+		passwordPolicy:       passwordPolicyFromEnv(),
+		accountDeletionGrace: durationFromEnv("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		deletedChirpsPolicy:  deletedChirpsPolicyFromEnv(),
+		exportDir:            exportDir,
+		exportTTL:            durationFromEnv("EXPORT_TTL", 24*time.Hour),
+		blobs:                blobStoreFromEnv(),
+		mediaLimits: media.Limits{
+			MaxBytes:  int64(intFromEnv("MEDIA_MAX_BYTES", 5<<20)),
+			MaxWidth:  intFromEnv("MEDIA_MAX_DIMENSION", 4096),
+			MaxHeight: intFromEnv("MEDIA_MAX_DIMENSION", 4096),
+		},
+		polkaWebhookSecret:      polkaWebhookSecret,
+		polkaSignatureTolerance: durationFromEnv("POLKA_SIGNATURE_TOLERANCE", 5*time.Minute),
+		webhookRetry: webhookRetry{
+			maxAttempts: intFromEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+			baseDelay:   durationFromEnv("WEBHOOK_RETRY_DELAY", 30*time.Second),
+			maxDelay:    durationFromEnv("WEBHOOK_MAX_RETRY_DELAY", 1*time.Hour),
+		},
+		webhookWake: make(chan struct{}, 1),
+		adminApiKey: os.Getenv("ADMIN_API_KEY"),
+		outboundRetry: webhookRetry{
+			maxAttempts: intFromEnv("OUTBOUND_WEBHOOK_MAX_ATTEMPTS", 6),
+			baseDelay:   durationFromEnv("OUTBOUND_WEBHOOK_RETRY_DELAY", 30*time.Second),
+			maxDelay:    durationFromEnv("OUTBOUND_WEBHOOK_MAX_RETRY_DELAY", 1*time.Hour),
+		},
+		endpointDisableAfter: intFromEnv("OUTBOUND_WEBHOOK_DISABLE_AFTER", 20),
+		webhookClient:        newWebhookClient(),
+		dispatchWake:         make(chan struct{}, 1),
+		plans:                plansFromEnv(),
+		previewClient:        safehttp.NewClient(linkPreviewTimeout),
+		previewWake:          make(chan struct{}, 1),
+		schedulerWake:        make(chan struct{}, 1),
+		exportWake:           make(chan struct{}, 1),
 	}
 
 	mux := http.NewServeMux()
@@ -93,7 +411,53 @@ func main() {
 	mux.HandleFunc(postRefreshPath, apiConfig.postRefreshHandler)
 	mux.HandleFunc(postRevokePath, apiConfig.postRevokeHandler)
 	mux.HandleFunc(deleteChirpIdPath, apiConfig.deleteChirpIdHandler)
+	mux.HandleFunc(putChirpIdPath, apiConfig.putChirpIdHandler)
+	mux.HandleFunc(getScheduledPath, apiConfig.getScheduledChirpsHandler)
+	mux.HandleFunc(putScheduledPath, apiConfig.putScheduledChirpHandler)
+	mux.HandleFunc(deleteScheduledPath, apiConfig.deleteScheduledChirpHandler)
+	mux.HandleFunc(postDraftsPath, apiConfig.postDraftsHandler)
+	mux.HandleFunc(getDraftsPath, apiConfig.getDraftsHandler)
+	mux.HandleFunc(putDraftPath, apiConfig.putDraftHandler)
+	mux.HandleFunc(deleteDraftPath, apiConfig.deleteDraftHandler)
+	mux.HandleFunc(postDraftPublishPath, apiConfig.postDraftPublishHandler)
 	mux.HandleFunc(postPolkaPath, apiConfig.postPolkaHandler)
+	mux.HandleFunc(passwordForgotPath, apiConfig.postPasswordForgotHandler)
+	mux.HandleFunc(passwordResetPath, apiConfig.postPasswordResetHandler)
+	mux.HandleFunc(verifyEmailPath, apiConfig.getUsersVerifyHandler)
+	mux.HandleFunc(twoFactorSetupPath, apiConfig.postTwoFactorSetupHandler)
+	mux.HandleFunc(twoFactorConfirmPath, apiConfig.postTwoFactorConfirmHandler)
+	mux.HandleFunc(loginTwoFactorPath, apiConfig.loginTwoFactorPostHandler)
+	mux.HandleFunc(patchUsersMePath, apiConfig.patchUsersMeHandler)
+	mux.HandleFunc(deleteUsersMePath, apiConfig.deleteUsersMeHandler)
+	mux.HandleFunc(postExportPath, apiConfig.postUsersMeExportHandler)
+	mux.HandleFunc(getExportPath, apiConfig.getUsersMeExportHandler)
+	mux.HandleFunc(postMediaPath, apiConfig.postMediaHandler)
+	mux.HandleFunc(getMediaPath, apiConfig.getMediaHandler)
+	mux.HandleFunc(adminWebhooksPath, apiConfig.adminWebhooksGetHandler)
+	mux.HandleFunc(adminReplayPath, apiConfig.adminWebhookReplayPostHandler)
+	mux.HandleFunc(postWebhooksPath, apiConfig.postWebhooksHandler)
+	mux.HandleFunc(getWebhooksPath, apiConfig.getWebhooksHandler)
+	mux.HandleFunc(deleteWebhookPath, apiConfig.deleteWebhookHandler)
+	mux.HandleFunc(enableWebhookPath, apiConfig.postWebhookEnableHandler)
+	mux.HandleFunc(getDeliveriesPath, apiConfig.getWebhookDeliveriesHandler)
+	mux.HandleFunc(getUserPath, apiConfig.getUserHandler)
+	mux.HandleFunc(getUserByHandlePath, apiConfig.getUserByHandleHandler)
+	mux.HandleFunc(postFollowPath, apiConfig.postUserFollowHandler)
+	mux.HandleFunc(deleteFollowPath, apiConfig.deleteUserFollowHandler)
+	mux.HandleFunc(postBlockPath, apiConfig.postUserBlockHandler)
+	mux.HandleFunc(deleteBlockPath, apiConfig.deleteUserBlockHandler)
+	mux.HandleFunc(postMutePath, apiConfig.postUserMuteHandler)
+	mux.HandleFunc(deleteMutePath, apiConfig.deleteUserMuteHandler)
+	mux.HandleFunc(getBlocksPath, apiConfig.getUsersMeBlocksHandler)
+	mux.HandleFunc(getMutesPath, apiConfig.getUsersMeMutesHandler)
+	mux.HandleFunc(postConversationsPath, apiConfig.postConversationsHandler)
+	mux.HandleFunc(getConversationsPath, apiConfig.getConversationsHandler)
+	mux.HandleFunc(postMessagesPath, apiConfig.postConversationMessagesHandler)
+	mux.HandleFunc(getMessagesPath, apiConfig.getConversationMessagesHandler)
+	mux.HandleFunc(postConversationReadPath, apiConfig.postConversationReadHandler)
+	mux.HandleFunc(postBookmarkPath, apiConfig.postChirpBookmarkHandler)
+	mux.HandleFunc(deleteChirpActionPath, apiConfig.deleteChirpActionHandler)
+	mux.HandleFunc(getBookmarksPath, apiConfig.getBookmarksHandler)
 
 	log.Printf("Registered file handler for dir %q on path %q", fsDir, fsPath)
 	log.Printf("Registered readiness endpoint on path %q", readinessPath)
@@ -108,17 +472,122 @@ func main() {
 	log.Printf("Registered POST refresh endpoint on path %q", postRefreshPath)
 	log.Printf("Registered POST revoke endpoint on path %q", postRevokePath)
 	log.Printf("Registered DELETE chirp by id endpoint on path %q", deleteChirpIdPath)
+	log.Printf("Registered PUT chirp by id endpoint on path %q", putChirpIdPath)
+	log.Printf("Registered GET scheduled chirps endpoint on path %q", getScheduledPath)
+	log.Printf("Registered PUT scheduled chirp endpoint on path %q", putScheduledPath)
+	log.Printf("Registered DELETE scheduled chirp endpoint on path %q", deleteScheduledPath)
+	log.Printf("Registered POST drafts endpoint on path %q", postDraftsPath)
+	log.Printf("Registered GET drafts endpoint on path %q", getDraftsPath)
+	log.Printf("Registered PUT draft endpoint on path %q", putDraftPath)
+	log.Printf("Registered DELETE draft endpoint on path %q", deleteDraftPath)
+	log.Printf("Registered POST draft publish endpoint on path %q", postDraftPublishPath)
 	log.Printf("Registered POST polka webhook endpoint on path %q", postPolkaPath)
+	log.Printf("Registered POST password forgot endpoint on path %q", passwordForgotPath)
+	log.Printf("Registered POST password reset endpoint on path %q", passwordResetPath)
+	log.Printf("Registered GET verify email endpoint on path %q", verifyEmailPath)
+	log.Printf("Registered POST two-factor setup endpoint on path %q", twoFactorSetupPath)
+	log.Printf("Registered POST two-factor confirm endpoint on path %q", twoFactorConfirmPath)
+	log.Printf("Registered POST two-factor login endpoint on path %q", loginTwoFactorPath)
+	log.Printf("Registered PATCH users me endpoint on path %q", patchUsersMePath)
+	log.Printf("Registered DELETE users me endpoint on path %q", deleteUsersMePath)
+	log.Printf("Registered POST export endpoint on path %q", postExportPath)
+	log.Printf("Registered GET export endpoint on path %q", getExportPath)
+	log.Printf("Registered POST media endpoint on path %q", postMediaPath)
+	log.Printf("Registered GET media endpoint on path %q", getMediaPath)
+	log.Printf("Registered GET admin webhooks endpoint on path %q", adminWebhooksPath)
+	log.Printf("Registered POST admin webhook replay endpoint on path %q", adminReplayPath)
+	log.Printf("Registered POST webhooks endpoint on path %q", postWebhooksPath)
+	log.Printf("Registered GET webhooks endpoint on path %q", getWebhooksPath)
+	log.Printf("Registered DELETE webhook endpoint on path %q", deleteWebhookPath)
+	log.Printf("Registered POST webhook enable endpoint on path %q", enableWebhookPath)
+	log.Printf("Registered GET webhook deliveries endpoint on path %q", getDeliveriesPath)
+	log.Printf("Registered GET user profile endpoint on path %q", getUserPath)
+	log.Printf("Registered GET user profile by handle endpoint on path %q", getUserByHandlePath)
+	log.Printf("Registered POST follow endpoint on path %q", postFollowPath)
+	log.Printf("Registered DELETE follow endpoint on path %q", deleteFollowPath)
+	log.Printf("Registered POST block endpoint on path %q", postBlockPath)
+	log.Printf("Registered DELETE block endpoint on path %q", deleteBlockPath)
+	log.Printf("Registered POST mute endpoint on path %q", postMutePath)
+	log.Printf("Registered DELETE mute endpoint on path %q", deleteMutePath)
+	log.Printf("Registered GET blocks endpoint on path %q", getBlocksPath)
+	log.Printf("Registered GET mutes endpoint on path %q", getMutesPath)
+	log.Printf("Registered POST conversations endpoint on path %q", postConversationsPath)
+	log.Printf("Registered GET conversations endpoint on path %q", getConversationsPath)
+	log.Printf("Registered POST messages endpoint on path %q", postMessagesPath)
+	log.Printf("Registered GET messages endpoint on path %q", getMessagesPath)
+	log.Printf("Registered POST conversation read endpoint on path %q", postConversationReadPath)
+	log.Printf("Registered POST bookmark endpoint on path %q", postBookmarkPath)
+	log.Printf("Registered DELETE bookmark endpoint on path %q", deleteChirpActionPath)
+	log.Printf("Registered GET bookmarks endpoint on path %q", getBookmarksPath)
 
 	server := &http.Server{
 		Addr:    port,
 		Handler: mux,
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	wg := sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		apiConfig.runJanitor(ctx, janitorInterval)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		apiConfig.runWebhookWorker(ctx, durationFromEnv("WEBHOOK_POLL_INTERVAL", 10*time.Second))
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		apiConfig.runWebhookDispatcher(ctx, durationFromEnv("WEBHOOK_POLL_INTERVAL", 10*time.Second))
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		apiConfig.runPreviewFetcher(ctx, durationFromEnv("LINK_PREVIEW_POLL_INTERVAL", 1*time.Minute))
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		apiConfig.runChirpScheduler(ctx, durationFromEnv("SCHEDULER_POLL_INTERVAL", 1*time.Minute))
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		apiConfig.runExportWorker(ctx, durationFromEnv("EXPORT_POLL_INTERVAL", 1*time.Minute))
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+
+		log.Printf("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Printf("Error shutting down server: %q", err)
+		}
+	}()
+
 	log.Printf("Starting server on port %q", port)
 	err = server.ListenAndServe()
 
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("error starting server: %q", err)
 	}
+
+	wg.Wait()
+	log.Printf("Server stopped")
 }
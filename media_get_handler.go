@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/media"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+
+	if cfg.blobs == nil {
+		respondWithError(w, http.StatusNotFound, "Media does not exist")
+		return
+	}
+
+	record, err := cfg.DB.MediaById(r.PathValue("mediaId"))
+	if err != nil {
+		if !errors.Is(err, database.MediaNotExists) {
+			log.Printf("Could not find media: %q", err)
+		}
+		respondWithError(w, http.StatusNotFound, "Media does not exist")
+		return
+	}
+
+	blob, err := cfg.blobs.Open(record.Id)
+	if err != nil {
+		if !errors.Is(err, media.ErrBlobNotExists) {
+			log.Printf("Could not open media %s: %q", record.Id, err)
+		}
+		respondWithError(w, http.StatusNotFound, "Media does not exist")
+		return
+	}
+	defer blob.Close()
+
+	// Uploads are never rewritten, browsers must not second guess the sniffed type
+	w.Header().Set("Content-Type", record.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", record.CreatedAt, blob)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/media"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type MediaResponse struct {
+	Id          string `json:"id"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// multipartOverhead Room for the multipart boundaries and headers on top of the file itself
+const multipartOverhead = 64 << 10
+
+func (cfg *apiConfig) postMediaHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if cfg.blobs == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Media uploads are disabled")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.mediaLimits.MaxBytes+multipartOverhead)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		maxBytesErr := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, media.ErrTooLarge.Error())
+			return
+		}
+		log.Printf("Could not read uploaded file: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Missing file field in multipart form")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, cfg.mediaLimits.MaxBytes+1))
+	if err != nil {
+		log.Printf("Could not read uploaded file: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not read file")
+		return
+	}
+
+	img, err := media.Process(data, cfg.mediaLimits)
+	if err != nil {
+		log.Printf("Rejected upload of user with id %d: %q", userId, err)
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, media.ErrUnsupportedType):
+			respondWithError(w, http.StatusUnsupportedMediaType, "only JPEG and PNG images are supported")
+		default:
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	mediaId, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not save file")
+		return
+	}
+	mediaId = mediaId[:32]
+
+	err = cfg.blobs.Put(mediaId, bytes.NewReader(img.Data))
+	if err != nil {
+		log.Printf("Could not store media %s: %q", mediaId, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not save file")
+		return
+	}
+
+	saved, err := cfg.DB.CreateMedia(database.Media{
+		Id:          mediaId,
+		OwnerId:     userId,
+		ContentType: img.ContentType,
+		Size:        len(img.Data),
+		Width:       img.Width,
+		Height:      img.Height,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		if deleteErr := cfg.blobs.Delete(mediaId); deleteErr != nil {
+			log.Printf("Could not remove orphaned media %s: %q", mediaId, deleteErr)
+		}
+		if errors.Is(err, database.UserNotExists) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		log.Printf("Could not save media %s: %q", mediaId, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not save file")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, MediaResponse{
+		Id:          saved.Id,
+		URL:         cfg.mediaURL(saved.Id),
+		ContentType: saved.ContentType,
+		Size:        saved.Size,
+		Width:       saved.Width,
+		Height:      saved.Height,
+	})
+}
+
+func (cfg *apiConfig) mediaURL(mediaId string) string {
+	return cfg.baseURL + "/media/" + mediaId
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/media"
+)
+
+func testJPEGWithExif(t *testing.T, width, height int) []byte {
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Could not encode test jpeg: %q", err)
+	}
+
+	exif := append([]byte("Exif\x00\x00"), []byte("GPS 40.4168N 3.7038W")...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	encoded := buf.Bytes()
+	return append(append(append([]byte{}, encoded[:2]...), segment...), encoded[2:]...)
+}
+
+func testPNGWithText(t *testing.T) []byte {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("Could not encode test png: %q", err)
+	}
+
+	text := []byte("Author\x00Jane Secret")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	encoded := buf.Bytes()
+	// Right after the signature and the IHDR chunk
+	return append(append(append([]byte{}, encoded[:33]...), chunk...), encoded[33:]...)
+}
+
+func TestPostMediaHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:          db,
+		jwtSecret:   "dGVzdA==",
+		baseURL:     "https://chirpy.test",
+		blobs:       media.DiskStore{Dir: t.TempDir()},
+		mediaLimits: media.Limits{MaxBytes: 64 << 10, MaxWidth: 64, MaxHeight: 64},
+	}
+
+	login := func(user string) LoginResponse {
+		createW := httptest.NewRecorder()
+		createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+		cfg.postUsersHandler(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(w, req)
+
+		loginResp := LoginResponse{}
+		if err := json.NewDecoder(w.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		return loginResp
+	}
+	owner := login(`{"email": "photographer@chirpy.com", "password": "aperture"}`)
+	other := login(`{"email": "thief@chirpy.com", "password": "borrowed"}`)
+
+	upload := func(token string, content []byte) *httptest.ResponseRecorder {
+		body := bytes.Buffer{}
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "upload.jpg")
+		part.Write(content)
+		form.Close()
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/media", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.postMediaHandler(w, req)
+		return w
+	}
+
+	cases := []struct {
+		content         []byte
+		wantCode        int
+		wantContentType string
+		secret          string
+	}{
+		{content: testJPEGWithExif(t, 16, 16), wantCode: 201, wantContentType: "image/jpeg", secret: "GPS"},
+		{content: testPNGWithText(t), wantCode: 201, wantContentType: "image/png", secret: "Jane Secret"},
+		{content: []byte("<html><script>alert(1)</script></html>"), wantCode: 415},
+		{content: testJPEGWithExif(t, 100, 10), wantCode: 400},
+		{content: bytes.Repeat([]byte{0xFF}, 128<<10), wantCode: 413},
+		// Data after the end of image marker, like an appended archive
+		{content: append(testJPEGWithExif(t, 16, 16), "PK\x03\x04 hidden archive"...), wantCode: 201, wantContentType: "image/jpeg", secret: "hidden archive"},
+	}
+
+	var mediaIds []string
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Post Media Handler Test Case %d", i), func(t *testing.T) {
+			w := upload(owner.Token, c.content)
+			if w.Code != c.wantCode {
+				t.Fatalf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+			if c.wantCode != 201 {
+				return
+			}
+
+			uploaded := MediaResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&uploaded); err != nil {
+				t.Fatalf("Could not decode media response: %q", err)
+			}
+			if want := "https://chirpy.test/media/" + uploaded.Id; uploaded.URL != want {
+				t.Errorf("Test failed (url): got %q, want %q", uploaded.URL, want)
+			}
+			mediaIds = append(mediaIds, uploaded.Id)
+
+			getW := httptest.NewRecorder()
+			getReq := httptest.NewRequest("GET", "/media/"+uploaded.Id, nil)
+			getReq.SetPathValue("mediaId", uploaded.Id)
+			cfg.getMediaHandler(getW, getReq)
+
+			if got := getW.Header().Get("Content-Type"); got != c.wantContentType {
+				t.Errorf("Test failed (content type): got %q, want %q", got, c.wantContentType)
+			}
+			served, _ := io.ReadAll(getW.Body)
+			if bytes.Contains(served, []byte(c.secret)) {
+				t.Errorf("Test failed (metadata): served file still contains %q", c.secret)
+			}
+			if _, _, err := image.Decode(bytes.NewReader(served)); err != nil {
+				t.Errorf("Test failed (decode): served file is not a valid image: %q", err)
+			}
+		})
+	}
+
+	chirpCases := []struct {
+		token    string
+		request  string
+		wantCode int
+	}{
+		{token: other.Token, request: fmt.Sprintf(`{"body": "Look", "media_ids": [%q]}`, mediaIds[0]), wantCode: 400},
+		{token: owner.Token, request: fmt.Sprintf(`{"body": "Look", "media_ids": [%q, %q]}`, mediaIds[0], mediaIds[0]), wantCode: 400},
+		{token: owner.Token, request: `{"body": "Look", "media_ids": ["a", "b", "c", "d", "e"]}`, wantCode: 400},
+		{token: owner.Token, request: fmt.Sprintf(`{"body": "Look", "media_ids": [%q, %q]}`, mediaIds[0], mediaIds[1]), wantCode: 201},
+	}
+
+	for i, c := range chirpCases {
+		t.Run(fmt.Sprintf("Post Chirp With Media Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(c.request))
+			req.Header.Set("Authorization", "Bearer "+c.token)
+			cfg.postChirpHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Fatalf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -13,9 +13,26 @@ func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, req *http.Request) {
 <body>
     <h1>Welcome, Chirpy Admin</h1>
     <p>Chirpy has been visited %d times!</p>
+    <p>Janitor ran %d times and swept:</p>
+    <ul>
+        <li>%d expired refresh tokens</li>
+        <li>%d expired password reset tokens</li>
+        <li>%d stale login attempts</li>
+        <li>%d deleted users</li>
+        <li>%d expired exports</li>
+        <li>%d old webhook events</li>
+        <li>%d lapsed Chirpy Red memberships</li>
+    </ul>
+    <p>%d webhook events were moved to the dead letters</p>
+    <p>%d outbound webhook endpoints were disabled after failing</p>
 </body>
 
-</html>`, cfg.fileserverHits))
+</html>`, cfg.fileserverHits, cfg.janitorRuns.Load(), cfg.sweptRefreshTokens.Load(),
+		cfg.sweptPasswordResetTokens.Load(), cfg.sweptLoginAttempts.Load(), cfg.purgedUsers.Load(),
+		cfg.sweptExports.Load(),
+		cfg.sweptWebhookEvents.Load(),
+		cfg.expiredSubscriptions.Load(), cfg.deadWebhookEvents.Load(),
+		cfg.disabledEndpoints.Load()))
 	w.Header().Add("Content-Type", "text/xml")
 	w.WriteHeader(200)
 	w.Write(bytes)
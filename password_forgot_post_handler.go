@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+const passwordResetTTL = 1 * time.Hour
+
+func (cfg *apiConfig) postPasswordForgotHandler(w http.ResponseWriter, r *http.Request) {
+
+	type forgotParams struct {
+		Email string `json:"email"`
+	}
+	params := forgotParams{}
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding password forgot params: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not decode params")
+		return
+	}
+
+	user, err := cfg.DB.UserByEmail(params.Email)
+	if err != nil {
+		// Same response whether the user exists or not, so emails can not be enumerated
+		if errors.Is(err, database.UserNotExists) {
+			log.Printf("Requested a password reset for unknown email %q", params.Email)
+			respondWithJSON(w, http.StatusNoContent, "")
+			return
+		}
+		log.Printf("Error finding user by email for password reset: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not request password reset")
+		return
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not request password reset")
+		return
+	}
+
+	err = cfg.DB.SavePasswordResetToken(user.Id, auth.HashOpaqueToken(token), time.Now().Add(passwordResetTTL))
+	if err != nil {
+		log.Printf("Could not save password reset token: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not request password reset")
+		return
+	}
+
+	body := fmt.Sprintf("Someone requested a password reset for your Chirpy account.\n\n"+
+		"Reset token: %s\n\n"+
+		"Send it along with your new password to POST /api/password/reset. It expires in %v.\n"+
+		"If it was not you, you can ignore this email.", token, passwordResetTTL)
+
+	// A failure here would tell the caller the email belongs to an account, the user can ask again
+	err = cfg.sendMail(user.Email, "Reset your Chirpy password", body)
+	if err != nil {
+		log.Printf("Could not send password reset email to user with id %d: %q", user.Id, err)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/policy"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) postPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+
+	type resetParams struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	params := resetParams{}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUserBodyBytes))
+	err := decoder.Decode(&params)
+	if err != nil {
+		maxBytesErr := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		log.Printf("Error decoding password reset params: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not decode params")
+		return
+	}
+
+	if params.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "token can not be empty")
+		return
+	}
+
+	if params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "password can not be empty")
+		return
+	}
+
+	err = cfg.passwordPolicy.Check(params.Password)
+	violationErr := policy.ViolationError{}
+	if errors.As(err, &violationErr) {
+		respondWithPolicyError(w, violationErr)
+		return
+	}
+
+	hashed, err := auth.HashPassword(params.Password)
+	if err != nil {
+		log.Printf("Could not hash new password: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not reset password")
+		return
+	}
+
+	userId, err := cfg.DB.ResetPassword(auth.HashOpaqueToken(params.Token), hashed)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidResetToken) {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		log.Printf("Could not reset password: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not reset password")
+		return
+	}
+
+	log.Printf("User with id %d reset their password", userId)
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+type sentMail struct {
+	to      string
+	subject string
+	body    string
+}
+
+type testMailer struct {
+	sent []sentMail
+	// err Returned instead of sending when set
+	err error
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
+
+// valueAfter Finds the value following prefix in a line of a sent email
+func (m *testMailer) valueAfter(prefix string) string {
+	if len(m.sent) == 0 {
+		return ""
+	}
+
+	for _, line := range strings.Split(m.sent[len(m.sent)-1].body, "\n") {
+		if value, found := strings.CutPrefix(line, prefix); found {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+func TestPasswordResetPostHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	mailer := &testMailer{}
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+		mailer:    mailer,
+	}
+
+	user := `{"email": "forgetful@chirpy.com", "password": "forgotten"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	loginW := httptest.NewRecorder()
+	loginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+	cfg.loginPostHandler(loginW, loginReq)
+
+	loginResp := LoginResponse{}
+	err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+	if err != nil {
+		t.Fatalf("Could not decode login response: %q", err)
+	}
+
+	sentBefore := len(mailer.sent)
+	unknownW := httptest.NewRecorder()
+	unknownReq := httptest.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email": "nobody@chirpy.com"}`))
+	cfg.postPasswordForgotHandler(unknownW, unknownReq)
+	if unknownW.Code != 204 || len(mailer.sent) != sentBefore {
+		t.Fatalf("Test failed (unknown email): got code %d and %d emails", unknownW.Code, len(mailer.sent)-sentBefore)
+	}
+
+	t.Run("Password Forgot Mail Failure Test", func(t *testing.T) {
+		mailer.err = errors.New("smtp server unavailable")
+		defer func() { mailer.err = nil }()
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email": "forgetful@chirpy.com"}`))
+		cfg.postPasswordForgotHandler(w, req)
+		if w.Code != 204 {
+			t.Errorf("Test failed (code): got %d, want %d", w.Code, 204)
+		}
+	})
+
+	forgotW := httptest.NewRecorder()
+	forgotReq := httptest.NewRequest("POST", "/api/password/forgot", strings.NewReader(`{"email": "forgetful@chirpy.com"}`))
+	cfg.postPasswordForgotHandler(forgotW, forgotReq)
+	if forgotW.Code != 204 {
+		t.Fatalf("Test failed (forgot code): got %d, want %d", forgotW.Code, 204)
+	}
+
+	token := mailer.valueAfter("Reset token:")
+	if token == "" {
+		t.Fatalf("Test failed, expected a reset token in the email")
+	}
+
+	cases := []struct {
+		request  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			request:  `{"token": "not-a-token", "password": "remembered"}`,
+			wantCode: 400,
+			wantBody: `{"error":"Invalid or expired token"}`,
+		},
+		{
+			request:  fmt.Sprintf(`{"token": %q, "password": ""}`, token),
+			wantCode: 400,
+			wantBody: `{"error":"password can not be empty"}`,
+		},
+		{
+			request:  fmt.Sprintf(`{"token": %q, "password": "remembered"}`, token),
+			wantCode: 204,
+			wantBody: `""`,
+		},
+		{
+			request:  fmt.Sprintf(`{"token": %q, "password": "reused"}`, token),
+			wantCode: 400,
+			wantBody: `{"error":"Invalid or expired token"}`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Password Reset Post Handler Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/password/reset", strings.NewReader(c.request))
+
+			cfg.postPasswordResetHandler(w, req)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	newLoginW := httptest.NewRecorder()
+	newLoginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email": "forgetful@chirpy.com", "password": "remembered"}`))
+	cfg.loginPostHandler(newLoginW, newLoginReq)
+	if newLoginW.Code != 200 {
+		t.Errorf("Test failed (login with new password): got %d, want %d", newLoginW.Code, 200)
+	}
+
+	refreshW := httptest.NewRecorder()
+	refreshReq := httptest.NewRequest("POST", "/api/refresh", nil)
+	refreshReq.Header.Set("Authorization", "Bearer "+loginResp.RefreshToken)
+	cfg.postRefreshHandler(refreshW, refreshReq)
+	if refreshW.Code != 401 {
+		t.Errorf("Test failed (refresh token issued before the reset): got %d, want %d", refreshW.Code, 401)
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
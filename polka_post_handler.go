@@ -1,66 +1,194 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
+)
+
+const (
+	polkaSignatureHeader = "Polka-Signature"
+	polkaTimestampHeader = "Polka-Timestamp"
+	polkaEventSource     = "polka"
+	maxWebhookBodyBytes  = 1 << 20
 )
 
 type PolkaParams struct {
+	Id    string `json:"id"`
 	Event string `json:"event"`
 	Data  Data   `json:"data"`
 }
 
 type Data struct {
 	UserId int `json:"user_id"`
+	// CurrentPeriodEnd Optional, memberships last defaultSubscriptionPeriod from the event when missing
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
 }
 
+const defaultSubscriptionPeriod = 30 * 24 * time.Hour
+
 func (cfg *apiConfig) postPolkaHandler(w http.ResponseWriter, r *http.Request) {
 
 	authHeader := r.Header.Get("Authorization")
 	token, found := strings.CutPrefix(authHeader, "ApiKey ")
-	if !found || token != cfg.polkaApiKey {
-		log.Printf("Invalid authorization header: %q", authHeader)
+	if !found || !auth.ConstantTimeEqual(token, cfg.polkaApiKey) {
+		log.Printf("Invalid authorization header for polka webhook")
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// The signature covers the raw bytes, they have to be read before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		log.Printf("Could not read polka webhook body: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not read body")
+		return
+	}
+
+	// Without a secret anyone with the api key could upgrade themselves, polka retries until it is configured
+	if cfg.polkaWebhookSecret == "" {
+		log.Printf("Rejected polka webhook because POLKA_WEBHOOK_SECRET is not set")
+		respondWithError(w, http.StatusServiceUnavailable, "Webhooks are not configured")
+		return
+	}
+
+	signature := r.Header.Get(polkaSignatureHeader)
+	err = auth.VerifyWebhookSignature(cfg.polkaWebhookSecret, signature, r.Header.Get(polkaTimestampHeader),
+		body, time.Now(), cfg.polkaSignatureTolerance)
+	if err != nil {
+		log.Printf("Rejected polka webhook: %q", err)
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	params := PolkaParams{}
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&params)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	err = decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Could not decode polka params: %q", err)
 		respondWithError(w, http.StatusInternalServerError, "Could not decode params")
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		respondWithJSON(w, http.StatusNoContent, "")
+	inboxId, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not store event")
 		return
 	}
+	inboxId = inboxId[:32]
+
+	// Without an event id a replay still carries the exact same signature
+	eventId := params.Id
+	if eventId == "" {
+		eventId = signature
+	}
+	if eventId == "" {
+		eventId = inboxId
+	}
+
+	_, err = cfg.DB.EnqueueWebhookEvent(database.InboxEvent{
+		Id:         inboxId,
+		Source:     polkaEventSource,
+		EventId:    eventId,
+		Payload:    string(body),
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		// Polka retries until it gets a 2xx, the stored copy is the one that gets processed
+		if errors.Is(err, database.ErrDuplicateEvent) {
+			log.Printf("Ignored replayed polka event %q", eventId)
+			respondWithJSON(w, http.StatusNoContent, "")
+			return
+		}
+		log.Printf("Could not store polka event: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not store event")
+		return
+	}
+
+	// Processing happens in the webhook worker, polka only needs to know the event is safely stored
+	cfg.wakeWebhookWorker()
+	respondWithJSON(w, http.StatusNoContent, "")
+}
+
+// handlePolkaEvent Returns the status code and error message to answer with, no content means success
+func (cfg *apiConfig) handlePolkaEvent(params PolkaParams) (code int, message string) {
 
 	user, err := cfg.DB.UserById(params.Data.UserId)
 	if err != nil {
+		if !subscriptionEvent(params.Event) {
+			return http.StatusNoContent, ""
+		}
 		log.Printf("Could not find user by id for polka webhook: %q", err)
-		respondWithError(w, http.StatusNotFound, "")
-		return
+		return http.StatusNotFound, ""
+	}
+
+	change, ok := subscriptionChange(params.Event, user.Subscription, params.Data.CurrentPeriodEnd, time.Now())
+	if !ok {
+		return http.StatusNoContent, ""
 	}
 
-	err = cfg.DB.MakeChirpyRed(user.Id)
+	updated, err := cfg.DB.UpdateSubscription(user.Id, change)
 	if err != nil {
 		if errors.Is(err, database.UserNotExists) {
-			log.Printf("Tried to update non-existing user to chirpy red: %q", err)
-			respondWithError(w, http.StatusNotFound, "")
-			return
+			log.Printf("Tried to update subscription of non-existing user: %q", err)
+			return http.StatusNotFound, ""
 		}
-		log.Printf("Could not update user to chirpy red: %q", err)
-		respondWithError(w, http.StatusInternalServerError, "Could not update user")
-		return
+		log.Printf("Could not update subscription: %q", err)
+		return http.StatusInternalServerError, "Could not update user"
 	}
 
-	respondWithJSON(w, http.StatusNoContent, "")
-	return
+	cfg.emitSubscriptionEvent(user, updated)
+	return http.StatusNoContent, ""
+}
+
+func subscriptionEvent(event string) bool {
+	_, ok := subscriptionChange(event, nil, time.Time{}, time.Time{})
+	return ok
+}
+
+// subscriptionChange Maps a polka event onto the subscription, unknown events are ignored
+func subscriptionChange(event string, current *database.Subscription, periodEnd, now time.Time) (database.SubscriptionChange, bool) {
+
+	currentEnd := time.Time{}
+	if current != nil {
+		currentEnd = current.CurrentPeriodEnd
+	}
+
+	change := database.SubscriptionChange{Event: event, At: now}
+	switch event {
+	case "user.upgraded":
+		change.Status = database.SubscriptionActive
+		change.PeriodEnd = now.Add(defaultSubscriptionPeriod)
+	case "user.renewed":
+		change.Status = database.SubscriptionActive
+		// Renewing early must not shorten what was already paid for
+		change.PeriodEnd = now.Add(defaultSubscriptionPeriod)
+		if currentEnd.After(now) {
+			change.PeriodEnd = currentEnd.Add(defaultSubscriptionPeriod)
+		}
+	case "user.payment_failed":
+		change.Status = database.SubscriptionPastDue
+		change.PeriodEnd = currentEnd
+	case "user.cancelled":
+		change.Status = database.SubscriptionCancelled
+		change.PeriodEnd = currentEnd
+	case "user.downgraded":
+		change.Status = database.SubscriptionExpired
+		change.PeriodEnd = now
+		return change, true
+	default:
+		return database.SubscriptionChange{}, false
+	}
+
+	if !periodEnd.IsZero() {
+		change.PeriodEnd = periodEnd
+	}
+	return change, true
 }
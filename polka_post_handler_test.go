@@ -3,13 +3,17 @@ package main
 import (
 	"errors"
 	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
 	"io"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPolkaPostHandler(t *testing.T) {
@@ -21,8 +25,10 @@ func TestPolkaPostHandler(t *testing.T) {
 	}
 
 	cfg := apiConfig{
-		DB:          db,
-		polkaApiKey: "1234",
+		DB:                      db,
+		polkaApiKey:             "1234",
+		polkaWebhookSecret:      "whsec_test",
+		polkaSignatureTolerance: 5 * time.Minute,
 	}
 
 	user := `{"email": "newuser@chirpy.com", "password": "hey!"}`
@@ -50,10 +56,11 @@ func TestPolkaPostHandler(t *testing.T) {
 			wantCode: 204,
 			wantBody: `""`,
 		},
+		// Acknowledged even though the user does not exist, the worker fails it later
 		{
 			request:  `{"event": "user.upgraded","data": {"user_id": 2}}`,
-			wantCode: 404,
-			wantBody: `{}`,
+			wantCode: 204,
+			wantBody: `""`,
 		},
 	}
 
@@ -62,6 +69,7 @@ func TestPolkaPostHandler(t *testing.T) {
 			w := httptest.NewRecorder()
 			req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(c.request))
 			req.Header.Set("Authorization", "ApiKey "+cfg.polkaApiKey)
+			signPolkaRequest(req, cfg.polkaWebhookSecret, c.request)
 
 			cfg.postPolkaHandler(w, req)
 
@@ -77,8 +85,262 @@ func TestPolkaPostHandler(t *testing.T) {
 		})
 	}
 
+	t.Run("Polka Post Handler Processing Test", func(t *testing.T) {
+		if got := cfg.processWebhookInbox(time.Now()); got != 3 {
+			t.Errorf("Test case failed (processed): got %d, want %d", got, 3)
+		}
+
+		upgraded, _ := db.UserById(1)
+		if !upgraded.IsChirpyRed {
+			t.Errorf("Test case failed (chirpy red): got %t, want %t", upgraded.IsChirpyRed, true)
+		}
+
+		dead, _ := db.WebhookEvents(database.InboxDead)
+		if len(dead) != 1 {
+			t.Errorf("Test case failed (dead letters): got %d, want %d", len(dead), 1)
+		}
+	})
+
 	err = os.Remove(testDbName)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatalf("Could not cleanup database file: %q", err)
 	}
 }
+
+func TestPolkaPostHandlerSignature(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:                      db,
+		polkaApiKey:             "1234",
+		polkaWebhookSecret:      "whsec_test",
+		polkaSignatureTolerance: 5 * time.Minute,
+	}
+
+	user := `{"email": "signed@chirpy.com", "password": "hey!"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	now := time.Now().Unix()
+	stale := time.Now().Add(-10 * time.Minute).Unix()
+	upgrade := `{"id": "evt_1", "event": "user.upgraded","data": {"user_id": 1}}`
+	anonymous := `{"event": "user.upgraded","data": {"user_id": 1}}`
+
+	cases := []struct {
+		request   string
+		apiKey    string
+		timestamp int64
+		signature string
+		wantCode  int
+	}{
+		// Wrong api key
+		{request: upgrade, apiKey: "4321", timestamp: now, signature: auth.SignWebhook("whsec_test", now, []byte(upgrade)), wantCode: 401},
+		// Missing signature
+		{request: upgrade, apiKey: "1234", timestamp: now, wantCode: 401},
+		// Signed with another secret
+		{request: upgrade, apiKey: "1234", timestamp: now, signature: auth.SignWebhook("other", now, []byte(upgrade)), wantCode: 401},
+		// Body tampered after signing
+		{request: upgrade, apiKey: "1234", timestamp: now, signature: auth.SignWebhook("whsec_test", now, []byte(anonymous)), wantCode: 401},
+		// Timestamp outside of the tolerance window
+		{request: upgrade, apiKey: "1234", timestamp: stale, signature: auth.SignWebhook("whsec_test", stale, []byte(upgrade)), wantCode: 401},
+		// Rotated secrets send more than one signature
+		{request: upgrade, apiKey: "1234", timestamp: now, signature: "v1=00ff, " + auth.SignWebhook("whsec_test", now, []byte(upgrade)), wantCode: 204},
+		// Replay of the same event id, acknowledged so polka stops retrying
+		{request: upgrade, apiKey: "1234", timestamp: now, signature: auth.SignWebhook("whsec_test", now, []byte(upgrade)), wantCode: 204},
+		{request: anonymous, apiKey: "1234", timestamp: now, signature: auth.SignWebhook("whsec_test", now, []byte(anonymous)), wantCode: 204},
+		// Replay of an event without id
+		{request: anonymous, apiKey: "1234", timestamp: now, signature: auth.SignWebhook("whsec_test", now, []byte(anonymous)), wantCode: 204},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Polka Post Handler Signature Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(c.request))
+			req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+			req.Header.Set("Polka-Timestamp", strconv.FormatInt(c.timestamp, 10))
+			if c.signature != "" {
+				req.Header.Set("Polka-Signature", c.signature)
+			}
+
+			cfg.postPolkaHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test case failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Polka Post Handler Replay Test", func(t *testing.T) {
+		if events, _ := db.WebhookEvents(database.InboxPending); len(events) != 2 {
+			t.Errorf("Test case failed (stored events): got %d, want %d", len(events), 2)
+		}
+	})
+
+	t.Run("Polka Post Handler Missing Secret Test", func(t *testing.T) {
+		unsigned := apiConfig{DB: db, polkaApiKey: "1234"}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(`{"id": "evt_9", "event": "user.upgraded","data": {"user_id": 1}}`))
+		req.Header.Set("Authorization", "ApiKey 1234")
+
+		unsigned.postPolkaHandler(w, req)
+
+		if w.Code != 503 {
+			t.Errorf("Test case failed (code): got %d, want %d", w.Code, 503)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
+
+func TestPolkaPostHandlerSubscriptionLifecycle(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:                      db,
+		polkaApiKey:             "1234",
+		polkaWebhookSecret:      "whsec_test",
+		polkaSignatureTolerance: 5 * time.Minute,
+	}
+
+	user := `{"email": "member@chirpy.com", "password": "hey!"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	periodEnd := time.Now().Add(10 * 24 * time.Hour).UTC().Truncate(time.Second)
+
+	cases := []struct {
+		request    string
+		wantStatus database.SubscriptionStatus
+		wantRed    bool
+	}{
+		{
+			request:    fmt.Sprintf(`{"id": "evt_1", "event": "user.upgraded", "data": {"user_id": 1, "current_period_end": %q}}`, periodEnd.Format(time.RFC3339)),
+			wantStatus: database.SubscriptionActive,
+			wantRed:    true,
+		},
+		{
+			request:    `{"id": "evt_2", "event": "user.payment_failed", "data": {"user_id": 1}}`,
+			wantStatus: database.SubscriptionPastDue,
+			wantRed:    true,
+		},
+		{
+			request:    `{"id": "evt_3", "event": "user.renewed", "data": {"user_id": 1}}`,
+			wantStatus: database.SubscriptionActive,
+			wantRed:    true,
+		},
+		{
+			request:    `{"id": "evt_4", "event": "user.cancelled", "data": {"user_id": 1}}`,
+			wantStatus: database.SubscriptionCancelled,
+			wantRed:    true,
+		},
+		{
+			request:    `{"id": "evt_5", "event": "user.downgraded", "data": {"user_id": 1}}`,
+			wantStatus: database.SubscriptionExpired,
+			wantRed:    false,
+		},
+		{
+			request:    `{"id": "evt_6", "event": "user.upgraded", "data": {"user_id": 1}}`,
+			wantStatus: database.SubscriptionActive,
+			wantRed:    true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Polka Post Handler Subscription Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(c.request))
+			req.Header.Set("Authorization", "ApiKey "+cfg.polkaApiKey)
+			signPolkaRequest(req, cfg.polkaWebhookSecret, c.request)
+
+			cfg.postPolkaHandler(w, req)
+
+			if w.Code != 204 {
+				t.Fatalf("Test case failed (code): got %d, want %d", w.Code, 204)
+			}
+			cfg.processWebhookInbox(time.Now())
+
+			member, _ := db.UserById(1)
+			if member.Subscription == nil || member.Subscription.Status != c.wantStatus {
+				t.Errorf("Test case failed (status): got %+v, want %s", member.Subscription, c.wantStatus)
+			}
+			if member.IsChirpyRed != c.wantRed {
+				t.Errorf("Test case failed (chirpy red): got %t, want %t", member.IsChirpyRed, c.wantRed)
+			}
+		})
+	}
+
+	t.Run("Polka Post Handler Renewal Extends Period Test", func(t *testing.T) {
+		member, _ := db.UserById(1)
+		// The first period ran from the upgrade to periodEnd, renewing early added another one on top
+		if want := periodEnd.Add(defaultSubscriptionPeriod); !member.Subscription.History[2].PeriodEnd.Equal(want) {
+			t.Errorf("Test case failed (period end): got %v, want %v", member.Subscription.History[2].PeriodEnd, want)
+		}
+		if got := len(member.Subscription.History); got != len(cases) {
+			t.Errorf("Test case failed (history): got %d entries, want %d", got, len(cases))
+		}
+	})
+
+	t.Run("Expire Lapsed Subscriptions Test", func(t *testing.T) {
+		expired, err := db.ExpireSubscriptions(time.Now().Add(defaultSubscriptionPeriod + time.Hour))
+		if err != nil {
+			t.Fatalf("Could not expire subscriptions: %q", err)
+		}
+		if len(expired) != 1 {
+			t.Errorf("Test case failed (expired): got %d, want %d", len(expired), 1)
+		}
+
+		member, _ := db.UserById(1)
+		if member.IsChirpyRed || member.Subscription.Status != database.SubscriptionExpired {
+			t.Errorf("Test case failed (expired membership): got %+v", member.Subscription)
+		}
+	})
+
+	t.Run("Migrate Legacy Members Test", func(t *testing.T) {
+		legacy, _ := db.CreateUser("legacy@chirpy.com", "hashed")
+		legacy.IsChirpyRed = true
+		db.UpdateUser(&legacy)
+
+		if got := planOf(legacy, time.Now()); got != planFree {
+			t.Errorf("Test case failed (plan before migration): got %s, want %s", got, planFree)
+		}
+
+		migrated, err := db.MigrateLegacySubscriptions(time.Now(), time.Now().Add(defaultSubscriptionPeriod))
+		if err != nil || migrated != 1 {
+			t.Fatalf("Test case failed (migrated): got %d and %v, want %d", migrated, err, 1)
+		}
+		legacy, _ = db.UserById(legacy.Id)
+		if got := planOf(legacy, time.Now()); got != planRed {
+			t.Errorf("Test case failed (plan after migration): got %s, want %s", got, planRed)
+		}
+		if got := planOf(legacy, time.Now().Add(defaultSubscriptionPeriod+time.Hour)); got != planFree {
+			t.Errorf("Test case failed (plan after period): got %s, want %s", got, planFree)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
+
+// signPolkaRequest Signs the request the way polka does, at the current time
+func signPolkaRequest(req *http.Request, secret, body string) {
+	now := time.Now().Unix()
+	req.Header.Set("Polka-Timestamp", strconv.FormatInt(now, 10))
+	req.Header.Set("Polka-Signature", auth.SignWebhook(secret, now, []byte(body)))
+}
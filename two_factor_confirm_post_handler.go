@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const recoveryCodesCount = 10
+
+func (cfg *apiConfig) postTwoFactorConfirmHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	type confirmParams struct {
+		Code string `json:"code"`
+	}
+	params := confirmParams{}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding two-factor confirm params: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not decode params")
+		return
+	}
+
+	user, err := cfg.DB.UserById(userId)
+	if err != nil {
+		log.Printf("Could not find user with id %d to confirm two-factor: %q", userId, err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	if user.PendingTOTPSecret == "" {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication setup was not started")
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(user.PendingTOTPSecret, params.Code, time.Now())
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not enable two-factor authentication")
+		return
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashOpaqueToken(auth.NormalizeRecoveryCode(code)))
+	}
+
+	err = cfg.DB.EnableTwoFactor(userId, step, hashes)
+	if err != nil {
+		if errors.Is(err, database.ErrTwoFactorEnabled) {
+			respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		log.Printf("Could not enable two-factor authentication: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not enable two-factor authentication")
+		return
+	}
+
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	// Only time the plain recovery codes are available, the database keeps their hashes
+	respondWithJSON(w, http.StatusOK, response{
+		RecoveryCodes: codes,
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) postUserBlockHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetId, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		log.Printf("Unable to convert path value to a valid integer: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Provided id is not valid")
+		return
+	}
+
+	err = cfg.DB.BlockUser(userId, targetId, time.Now().UTC())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrSelfRelation):
+			respondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, database.UserNotExists):
+			respondWithError(w, http.StatusNotFound, "User does not exist")
+		default:
+			log.Printf("Could not block user: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not block user")
+		}
+		return
+	}
+
+	log.Printf("User with id %d blocked user with id %d", userId, targetId)
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestBlocksAndMutes(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	handles := []string{"alice", "bob", "carol"}
+	tokens := make([]string, len(handles))
+	for i, handle := range handles {
+		user := fmt.Sprintf(`{"email": "%s@chirpy.com", "password": "tangerine-lantern-42"}`, handle)
+		createW := httptest.NewRecorder()
+		cfg.postUsersHandler(createW, httptest.NewRequest("POST", "/api/users", strings.NewReader(user)))
+
+		loginW := httptest.NewRecorder()
+		cfg.loginPostHandler(loginW, httptest.NewRequest("POST", "/api/login", strings.NewReader(user)))
+		loginResp := LoginResponse{}
+		err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+		if err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		tokens[i] = loginResp.Token
+
+		dbUser, _ := db.UserById(i + 1)
+		dbUser.Handle = handle
+		if err := db.UpdateUser(&dbUser); err != nil {
+			t.Fatalf("Could not set handle: %q", err)
+		}
+
+		chirpW := httptest.NewRecorder()
+		chirpReq := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(fmt.Sprintf(`{"body": "Hello from %s"}`, handle)))
+		chirpReq.Header.Set("Authorization", "Bearer "+loginResp.Token)
+		cfg.postChirpHandler(chirpW, chirpReq)
+	}
+	if err := db.FollowUser(2, 1, time.Now()); err != nil {
+		t.Fatalf("Could not follow user: %q", err)
+	}
+
+	relate := func(method, kind, token, userId string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/api/users/"+userId+"/"+kind, nil)
+		req.SetPathValue("userId", userId)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		switch method + " " + kind {
+		case "POST block":
+			cfg.postUserBlockHandler(w, req)
+		case "DELETE block":
+			cfg.deleteUserBlockHandler(w, req)
+		case "POST mute":
+			cfg.postUserMuteHandler(w, req)
+		case "DELETE mute":
+			cfg.deleteUserMuteHandler(w, req)
+		case "POST follow":
+			cfg.postUserFollowHandler(w, req)
+		}
+		return w.Code
+	}
+	listAuthors := func(token string) []int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.getChirpHandler(w, req)
+
+		chirps := []database.Chirp{}
+		_ = json.NewDecoder(w.Body).Decode(&chirps)
+		authors := make([]int, 0, len(chirps))
+		for _, chirp := range chirps {
+			authors = append(authors, chirp.AuthorId)
+		}
+		return authors
+	}
+	getChirp := func(token, chirpId string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps/"+chirpId, nil)
+		req.SetPathValue("chirpId", chirpId)
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.chirpIdGetHandler(w, req)
+		return w.Code
+	}
+
+	relationCases := []struct {
+		method   string
+		kind     string
+		token    string
+		userId   string
+		wantCode int
+	}{
+		{method: "POST", kind: "block", token: tokens[0], userId: "1", wantCode: 400},
+		{method: "POST", kind: "block", token: tokens[0], userId: "9", wantCode: 404},
+		{method: "POST", kind: "block", token: tokens[0], userId: "2", wantCode: 204},
+		{method: "POST", kind: "block", token: tokens[0], userId: "2", wantCode: 204},
+		// Neither side can follow the other while blocked
+		{method: "POST", kind: "follow", token: tokens[1], userId: "1", wantCode: 403},
+		{method: "POST", kind: "follow", token: tokens[0], userId: "2", wantCode: 403},
+		{method: "POST", kind: "mute", token: tokens[0], userId: "3", wantCode: 204},
+	}
+
+	for i, c := range relationCases {
+		t.Run(fmt.Sprintf("Blocks And Mutes Relation Test Case %d", i), func(t *testing.T) {
+			if got := relate(c.method, c.kind, c.token, c.userId); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Blocks And Mutes Reads Test", func(t *testing.T) {
+		// Alice muted Carol, so Carol is only gone from her listing
+		if got := listAuthors(tokens[0]); !slices.Equal(got, []int{1}) {
+			t.Errorf("Test failed (alice listing): got %v, want %v", got, []int{1})
+		}
+		if got := listAuthors(tokens[1]); !slices.Equal(got, []int{2, 3}) {
+			t.Errorf("Test failed (bob listing): got %v, want %v", got, []int{2, 3})
+		}
+		if got := listAuthors(tokens[2]); !slices.Equal(got, []int{1, 2, 3}) {
+			t.Errorf("Test failed (carol listing): got %v, want %v", got, []int{1, 2, 3})
+		}
+
+		if got := getChirp(tokens[1], "1"); got != 404 {
+			t.Errorf("Test failed (bob reading alice): got %d, want %d", got, 404)
+		}
+		if got := getChirp(tokens[0], "2"); got != 404 {
+			t.Errorf("Test failed (alice reading bob): got %d, want %d", got, 404)
+		}
+		if got := getChirp(tokens[0], "3"); got != 200 {
+			t.Errorf("Test failed (alice reading muted carol): got %d, want %d", got, 200)
+		}
+
+		stats, _ := db.ProfileStats(1)
+		if stats.Followers != 0 {
+			t.Errorf("Test failed (follow removed): got %d followers, want %d", stats.Followers, 0)
+		}
+	})
+
+	t.Run("Blocks And Mutes Mentions Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "@alice @carol look"}`))
+		req.Header.Set("Authorization", "Bearer "+tokens[1])
+		cfg.postChirpHandler(w, req)
+
+		chirp := database.Chirp{}
+		_ = json.NewDecoder(w.Body).Decode(&chirp)
+		if len(chirp.Entities) != 1 || chirp.Entities[0].Handle != "carol" {
+			t.Errorf("Test failed (mentions): got %+v, want only carol", chirp.Entities)
+		}
+	})
+
+	t.Run("Blocks And Mutes List Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/users/me/blocks", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens[0])
+		cfg.getUsersMeBlocksHandler(w, req)
+
+		blocks := []database.Relation{}
+		_ = json.NewDecoder(w.Body).Decode(&blocks)
+		if len(blocks) != 1 || blocks[0].TargetId != 2 {
+			t.Errorf("Test failed (blocks): got %+v", blocks)
+		}
+	})
+
+	t.Run("Blocks And Mutes Undo Test", func(t *testing.T) {
+		if got := relate("DELETE", "block", tokens[0], "2"); got != 204 {
+			t.Errorf("Test failed (unblock code): got %d, want %d", got, 204)
+		}
+		if got := relate("DELETE", "mute", tokens[0], "3"); got != 204 {
+			t.Errorf("Test failed (unmute code): got %d, want %d", got, 204)
+		}
+		if got := listAuthors(tokens[0]); !slices.Equal(got, []int{1, 2, 3, 2}) {
+			t.Errorf("Test failed (alice listing): got %v, want %v", got, []int{1, 2, 3, 2})
+		}
+		if got := relate("POST", "follow", tokens[1], "1"); got != 204 {
+			t.Errorf("Test failed (follow after unblock): got %d, want %d", got, 204)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"net/http"
+)
+
+func (cfg *apiConfig) getUserByHandleHandler(w http.ResponseWriter, r *http.Request) {
+
+	user, err := cfg.DB.UserByHandle(r.PathValue("handle"))
+	cfg.respondWithProfile(w, r, user, err)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (cfg *apiConfig) deleteUserFollowHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	followeeId, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		log.Printf("Unable to convert path value to a valid integer: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Provided id is not valid")
+		return
+	}
+
+	err = cfg.DB.UnfollowUser(userId, followeeId)
+	if err != nil {
+		log.Printf("Could not unfollow user: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not unfollow user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, "")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PublicProfile The view of a user anyone can see, it must never include the email or security settings
+type PublicProfile struct {
+	ID             int    `json:"id"`
+	Handle         string `json:"handle,omitempty"`
+	DisplayName    string `json:"display_name,omitempty"`
+	Bio            string `json:"bio,omitempty"`
+	AvatarURL      string `json:"avatar_url,omitempty"`
+	IsChirpyRed    bool   `json:"is_chirpy_red"`
+	PinnedChirpId  int    `json:"pinned_chirp_id,omitempty"`
+	ChirpCount     int    `json:"chirp_count"`
+	FollowerCount  int    `json:"follower_count"`
+	FollowingCount int    `json:"following_count"`
+}
+
+func (cfg *apiConfig) getUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		log.Printf("Unable to convert path value to a valid integer: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Provided id is not valid")
+		return
+	}
+
+	user, err := cfg.DB.UserById(id)
+	cfg.respondWithProfile(w, r, user, err)
+}
+
+// respondWithProfile Users waiting to be purged look the same as users that do not exist.
+// The pinned chirp is only shown to viewers who can read it.
+func (cfg *apiConfig) respondWithProfile(w http.ResponseWriter, r *http.Request, user database.User, err error) {
+
+	if errors.Is(err, database.UserNotExists) || (err == nil && user.Deleted()) {
+		respondWithError(w, http.StatusNotFound, "User does not exist")
+		return
+	}
+	if err != nil {
+		log.Printf("Could not retrieve user profile: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user")
+		return
+	}
+
+	viewerId, err := cfg.viewerId(r)
+	if err != nil {
+		log.Printf("Could not identify profile viewer: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	pinnedChirpId := 0
+	if user.PinnedChirpId != 0 {
+		if _, err := cfg.DB.ChirpById(user.PinnedChirpId, viewerId); err == nil {
+			pinnedChirpId = user.PinnedChirpId
+		}
+	}
+
+	stats, err := cfg.DB.ProfileStats(user.Id)
+	if err != nil {
+		log.Printf("Could not count profile stats of user with id %d: %q", user.Id, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, PublicProfile{
+		ID:             user.Id,
+		Handle:         user.Handle,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		AvatarURL:      user.AvatarURL,
+		IsChirpyRed:    user.Subscription.Active(time.Now()),
+		PinnedChirpId:  pinnedChirpId,
+		ChirpCount:     stats.Chirps,
+		FollowerCount:  stats.Followers,
+		FollowingCount: stats.Following,
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestGetUserHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	login := func(user string) LoginResponse {
+		createW := httptest.NewRecorder()
+		createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+		cfg.postUsersHandler(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(w, req)
+
+		loginResp := LoginResponse{}
+		if err := json.NewDecoder(w.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		return loginResp
+	}
+	alice := login(`{"email": "alice@chirpy.com", "password": "wonderland"}`)
+	bob := login(`{"email": "bob@chirpy.com", "password": "builder"}`)
+
+	patchCases := []struct {
+		token    string
+		request  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			token:    alice.Token,
+			request:  `{"handle": "alice", "display_name": " Alice ", "bio": "Down the rabbit hole", "avatar_url": "https://img.chirpy.com/alice.png"}`,
+			wantCode: 200,
+			wantBody: `{"email":"alice@chirpy.com","id":1,"is_chirpy_red":false,"email_verified":false,"handle":"alice",` +
+				`"display_name":"Alice","bio":"Down the rabbit hole","avatar_url":"https://img.chirpy.com/alice.png"}`,
+		},
+		{
+			token:    bob.Token,
+			request:  `{"handle": "ALICE"}`,
+			wantCode: 400,
+			wantBody: `{"error":"handle already exists"}`,
+		},
+		{
+			token:    bob.Token,
+			request:  `{"handle": "b"}`,
+			wantCode: 400,
+			wantBody: `{"error":"handle must be 3 to 30 letters, digits or underscores"}`,
+		},
+		{
+			token:    bob.Token,
+			request:  `{"avatar_url": "javascript:alert(1)"}`,
+			wantCode: 400,
+			wantBody: `{"error":"avatar_url must be an absolute http or https URL"}`,
+		},
+	}
+
+	for i, c := range patchCases {
+		t.Run(fmt.Sprintf("Patch Profile Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PATCH", "/api/users/me", strings.NewReader(c.request))
+			req.Header.Set("Authorization", "Bearer "+c.token)
+			cfg.patchUsersMeHandler(w, req)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	followW := httptest.NewRecorder()
+	followReq := httptest.NewRequest("POST", "/api/users/1/follow", nil)
+	followReq.SetPathValue("userId", "1")
+	followReq.Header.Set("Authorization", "Bearer "+bob.Token)
+	cfg.postUserFollowHandler(followW, followReq)
+	if followW.Code != 204 {
+		t.Fatalf("Test failed (follow code): got %d, want %d", followW.Code, 204)
+	}
+
+	chirpW := httptest.NewRecorder()
+	chirpReq := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "Curiouser and curiouser"}`))
+	chirpReq.Header.Set("Authorization", "Bearer "+alice.Token)
+	cfg.postChirpHandler(chirpW, chirpReq)
+
+	alicePublic := `{"id":1,"handle":"alice","display_name":"Alice","bio":"Down the rabbit hole",` +
+		`"avatar_url":"https://img.chirpy.com/alice.png","is_chirpy_red":false,"chirp_count":1,"follower_count":1,"following_count":0}`
+
+	getCases := []struct {
+		path     string
+		byHandle bool
+		value    string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/api/users/1", value: "1", wantCode: 200, wantBody: alicePublic},
+		{path: "/api/users/by-handle/Alice", byHandle: true, value: "Alice", wantCode: 200, wantBody: alicePublic},
+		{path: "/api/users/2", value: "2", wantCode: 200, wantBody: `{"id":2,"is_chirpy_red":false,"chirp_count":0,"follower_count":0,"following_count":1}`},
+		{path: "/api/users/99", value: "99", wantCode: 404, wantBody: `{"error":"User does not exist"}`},
+		{path: "/api/users/by-handle/nobody", byHandle: true, value: "nobody", wantCode: 404, wantBody: `{"error":"User does not exist"}`},
+		{path: "/api/users/abc", value: "abc", wantCode: 400, wantBody: `{"error":"Provided id is not valid"}`},
+	}
+
+	for i, c := range getCases {
+		t.Run(fmt.Sprintf("Get User Handler Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", c.path, nil)
+			if c.byHandle {
+				req.SetPathValue("handle", c.value)
+				cfg.getUserByHandleHandler(w, req)
+			} else {
+				req.SetPathValue("userId", c.value)
+				cfg.getUserHandler(w, req)
+			}
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if strings.Contains(string(resp), "@chirpy.com") {
+				t.Errorf("Test failed (email leaked): %s", resp)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
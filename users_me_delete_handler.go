@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) deleteUsersMeHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	type deleteParams struct {
+		Password string `json:"password"`
+	}
+	params := deleteParams{}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding delete user params: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode params")
+		return
+	}
+
+	user, err := cfg.DB.UserById(userId)
+	if err != nil || user.Deleted() {
+		log.Printf("Could not find user to delete: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if !cfg.confirmCurrentPassword(w, r, user, params.Password) {
+		return
+	}
+
+	err = cfg.DB.SoftDeleteUser(userId, time.Now())
+	if err != nil {
+		log.Printf("Could not delete user: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not delete user")
+		return
+	}
+
+	log.Printf("User with id %d deleted their account, it will be purged after %v", userId, cfg.accountDeletionGrace)
+	respondWithJSON(w, http.StatusNoContent, "")
+}
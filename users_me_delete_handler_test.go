@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestDeleteUsersMeHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:                   db,
+		jwtSecret:            "dGVzdA==",
+		accountDeletionGrace: 24 * time.Hour,
+		deletedChirpsPolicy:  database.AnonymizeChirps,
+	}
+
+	user := `{"email": "leaving@chirpy.com", "password": "goodbye"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	login := func() LoginResponse {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(w, req)
+
+		loginResp := LoginResponse{}
+		if err := json.NewDecoder(w.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		return loginResp
+	}
+	loginResp := login()
+
+	chirpW := httptest.NewRecorder()
+	chirpReq := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "Last chirp"}`))
+	chirpReq.Header.Set("Authorization", "Bearer "+loginResp.Token)
+	cfg.postChirpHandler(chirpW, chirpReq)
+
+	deleteMe := func(request string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("DELETE", "/api/users/me", strings.NewReader(request))
+		req.Header.Set("Authorization", "Bearer "+loginResp.Token)
+		cfg.deleteUsersMeHandler(w, req)
+		return w.Code
+	}
+
+	getChirp := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/chirps/1", nil)
+		req.SetPathValue("chirpId", "1")
+		cfg.chirpIdGetHandler(w, req)
+		return w.Code
+	}
+
+	cases := []struct {
+		request  string
+		wantCode int
+	}{
+		{request: `{}`, wantCode: 400},
+		{request: `{"password": "wrong"}`, wantCode: 403},
+		{request: `{"password": "goodbye"}`, wantCode: 204},
+		{request: `{"password": "goodbye"}`, wantCode: 401},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Delete Users Me Handler Test Case %d", i), func(t *testing.T) {
+			if got := deleteMe(c.request); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	if got := getChirp(); got != 404 {
+		t.Errorf("Test failed (chirps hidden during grace period): got %d, want %d", got, 404)
+	}
+
+	refreshW := httptest.NewRecorder()
+	refreshReq := httptest.NewRequest("POST", "/api/refresh", nil)
+	refreshReq.Header.Set("Authorization", "Bearer "+loginResp.RefreshToken)
+	cfg.postRefreshHandler(refreshW, refreshReq)
+	if refreshW.Code != 401 {
+		t.Errorf("Test failed (refresh tokens revoked): got %d, want %d", refreshW.Code, 401)
+	}
+
+	// Logging in during the grace period restores the account
+	loginResp = login()
+	if got := getChirp(); got != 200 {
+		t.Errorf("Test failed (restored chirps): got %d, want %d", got, 200)
+	}
+
+	if got := deleteMe(`{"password": "goodbye"}`); got != 204 {
+		t.Fatalf("Test failed (delete again): got %d, want %d", got, 204)
+	}
+
+	cfg.sweep(time.Now())
+	if got := cfg.purgedUsers.Load(); got != 0 {
+		t.Errorf("Test failed (purged during grace period): got %d, want %d", got, 0)
+	}
+
+	cfg.sweep(time.Now().Add(25 * time.Hour))
+	if got := cfg.purgedUsers.Load(); got != 1 {
+		t.Errorf("Test failed (purged after grace period): got %d, want %d", got, 1)
+	}
+
+	chirp, err := db.ChirpById(1, 0)
+	if err != nil || chirp.AuthorId != 0 {
+		t.Errorf("Test failed, expected chirp to be anonymized: %+v %v", chirp, err)
+	}
+
+	// The email is free again, and the new id does not collide with the remaining users
+	recreateW := httptest.NewRecorder()
+	recreateReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"email": "other@chirpy.com", "password": "hello"}`))
+	cfg.postUsersHandler(recreateW, recreateReq)
+	againW := httptest.NewRecorder()
+	againReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(againW, againReq)
+
+	if againW.Code != 201 || !strings.Contains(againW.Body.String(), `"id":2`) {
+		t.Errorf("Test failed (sign up again): got %d %s", againW.Code, againW.Body.String())
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func (cfg *apiConfig) getUsersMeExportHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := cfg.DB.ExportById(r.PathValue("exportId"))
+	// Exports of other users do not exist as far as this user is concerned
+	if err != nil || export.UserId != userId || export.ExpiresAt.Before(time.Now()) {
+		log.Printf("Export %q is not available to user with id %d: %v", r.PathValue("exportId"), userId, err)
+		respondWithError(w, http.StatusNotFound, "Export does not exist or expired")
+		return
+	}
+
+	switch export.Status {
+	case database.ExportPending:
+		respondWithJSON(w, http.StatusAccepted, ExportResponse{
+			Id:        export.Id,
+			Status:    export.Status,
+			CreatedAt: export.CreatedAt,
+			ExpiresAt: export.ExpiresAt,
+		})
+		return
+	case database.ExportFailed:
+		respondWithError(w, http.StatusInternalServerError, "Export failed, please request a new one")
+		return
+	}
+
+	f, err := os.Open(export.Path)
+	if err != nil {
+		log.Printf("Could not open archive of export %s: %q", export.Id, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve export")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"chirpy-export-%s.zip\"", export.Id))
+	http.ServeContent(w, r, "", export.CreatedAt, f)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestUsersMeExportHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+		exportDir: t.TempDir(),
+		exportTTL: time.Hour,
+	}
+
+	login := func(user string) LoginResponse {
+		createW := httptest.NewRecorder()
+		createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+		cfg.postUsersHandler(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(w, req)
+
+		loginResp := LoginResponse{}
+		if err := json.NewDecoder(w.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		return loginResp
+	}
+	owner := login(`{"email": "exporter@chirpy.com", "password": "mydata"}`)
+	other := login(`{"email": "nosy@chirpy.com", "password": "notmine"}`)
+
+	chirpW := httptest.NewRecorder()
+	chirpReq := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "Exported chirp"}`))
+	chirpReq.Header.Set("Authorization", "Bearer "+owner.Token)
+	cfg.postChirpHandler(chirpW, chirpReq)
+
+	postW := httptest.NewRecorder()
+	postReq := httptest.NewRequest("POST", "/api/users/me/export", nil)
+	postReq.Header.Set("Authorization", "Bearer "+owner.Token)
+	cfg.postUsersMeExportHandler(postW, postReq)
+
+	if postW.Code != 202 {
+		t.Fatalf("Test failed (request export code): got %d, want %d", postW.Code, 202)
+	}
+
+	export := ExportResponse{}
+	if err := json.NewDecoder(postW.Body).Decode(&export); err != nil {
+		t.Fatalf("Could not decode export response: %q", err)
+	}
+
+	t.Run("Export Already Pending Test", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/users/me/export", nil)
+		req.Header.Set("Authorization", "Bearer "+owner.Token)
+		cfg.postUsersMeExportHandler(w, req)
+
+		if w.Code != 409 {
+			t.Fatalf("Test failed (code): got %d, want %d", w.Code, 409)
+		}
+	})
+
+	getExport := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/users/me/export/"+export.Id, nil)
+		req.SetPathValue("exportId", export.Id)
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.getUsersMeExportHandler(w, req)
+		return w
+	}
+
+	t.Run("Export Of Another User Test", func(t *testing.T) {
+		if got := getExport(other.Token).Code; got != 404 {
+			t.Fatalf("Test failed (code): got %d, want %d", got, 404)
+		}
+	})
+
+	t.Run("Export Download Test", func(t *testing.T) {
+		if got := getExport(owner.Token).Code; got != 202 {
+			t.Fatalf("Test failed (pending code): got %d, want %d", got, 202)
+		}
+		if built := cfg.buildPendingExports(context.Background()); built != 1 {
+			t.Fatalf("Test failed (built): got %d, want %d", built, 1)
+		}
+
+		w := getExport(owner.Token)
+		if w.Code != 200 {
+			t.Fatalf("Test failed (code): got %d, want %d", w.Code, 200)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/zip" {
+			t.Fatalf("Test failed (content type): got %q, want %q", got, "application/zip")
+		}
+
+		body, _ := io.ReadAll(w.Body)
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatalf("Could not read export archive: %q", err)
+		}
+
+		files := map[string]string{}
+		for _, f := range archive.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+
+		for _, name := range []string{"profile.json", "chirps.json", "sessions.json", "exports.json"} {
+			if _, ok := files[name]; !ok {
+				t.Errorf("Test failed (missing file): %s not in archive", name)
+			}
+		}
+		if !strings.Contains(files["profile.json"], "exporter@chirpy.com") {
+			t.Errorf("Test failed (profile): got %s", files["profile.json"])
+		}
+		if strings.Contains(files["profile.json"], "$argon2id$") {
+			t.Errorf("Test failed (profile): password hash was exported")
+		}
+		if !strings.Contains(files["chirps.json"], "Exported chirp") {
+			t.Errorf("Test failed (chirps): got %s", files["chirps.json"])
+		}
+	})
+
+	t.Run("Expired Export Test", func(t *testing.T) {
+		stats, err := cfg.DB.PurgeExpired(time.Now().Add(2 * time.Hour))
+		if err != nil {
+			t.Fatalf("Could not purge expired records: %q", err)
+		}
+		if stats.Exports != 1 {
+			t.Fatalf("Test failed (purged exports): got %d, want %d", stats.Exports, 1)
+		}
+		if got := getExport(owner.Token).Code; got != 404 {
+			t.Fatalf("Test failed (code): got %d, want %d", got, 404)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
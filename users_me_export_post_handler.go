@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/media"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ExportResponse struct {
+	Id        string                `json:"id"`
+	Status    database.ExportStatus `json:"status"`
+	CreatedAt time.Time             `json:"created_at"`
+	ExpiresAt time.Time             `json:"expires_at"`
+}
+
+func (cfg *apiConfig) postUsersMeExportHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	exportId, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create export")
+		return
+	}
+
+	now := time.Now()
+	export, err := cfg.DB.CreateExport(exportId[:32], userId, now, now.Add(cfg.exportTTL))
+	if err != nil {
+		if errors.Is(err, database.UserNotExists) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if errors.Is(err, database.ErrExportPending) {
+			respondWithError(w, http.StatusConflict, "An export is already being prepared, wait for it to finish")
+			return
+		}
+		log.Printf("Could not create export: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not create export")
+		return
+	}
+
+	cfg.wakeExportWorker()
+
+	respondWithJSON(w, http.StatusAccepted, ExportResponse{
+		Id:        export.Id,
+		Status:    export.Status,
+		CreatedAt: export.CreatedAt,
+		ExpiresAt: export.ExpiresAt,
+	})
+}
+
+func (cfg *apiConfig) wakeExportWorker() {
+	if cfg.exportWake == nil {
+		return
+	}
+	select {
+	case cfg.exportWake <- struct{}{}:
+	default:
+	}
+}
+
+// runExportWorker Builds pending exports one at a time. The first run picks up the exports that were pending
+// when the server stopped.
+func (cfg *apiConfig) runExportWorker(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting export worker, checking for pending exports every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cfg.buildPendingExports(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping export worker: %q", ctx.Err())
+			return
+		case <-cfg.exportWake:
+			cfg.buildPendingExports(ctx)
+		case <-ticker.C:
+			cfg.buildPendingExports(ctx)
+		}
+	}
+}
+
+// buildPendingExports Returns how many exports were built, it stops between exports once ctx is done
+func (cfg *apiConfig) buildPendingExports(ctx context.Context) int {
+
+	pending, err := cfg.DB.PendingExports()
+	if err != nil {
+		log.Printf("Export worker could not load pending exports: %q", err)
+		return 0
+	}
+
+	built := 0
+	for _, export := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		cfg.buildExport(export)
+		built++
+	}
+
+	return built
+}
+
+// buildExport Writes the user data as a zip of JSON files, the export is marked as failed if anything goes wrong.
+func (cfg *apiConfig) buildExport(export database.Export) {
+
+	path, err := cfg.writeExportArchive(export)
+	if err != nil {
+		log.Printf("Could not build export %s for user with id %d: %q", export.Id, export.UserId, err)
+		path = ""
+	}
+
+	err = cfg.DB.FinishExport(export.Id, path)
+	if err != nil {
+		log.Printf("Could not finish export %s: %q", export.Id, err)
+		return
+	}
+
+	log.Printf("Finished export %s for user with id %d", export.Id, export.UserId)
+}
+
+func (cfg *apiConfig) writeExportArchive(export database.Export) (string, error) {
+
+	data, err := cfg.DB.UserData(export.UserId)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(cfg.exportDir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(cfg.exportDir, export.Id+".zip")
+	tmp, err := os.CreateTemp(cfg.exportDir, export.Id+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	files := map[string]any{
+		"profile.json":   data.Profile,
+		"chirps.json":    data.Chirps,
+		"sessions.json":  data.Sessions,
+		"exports.json":   data.Exports,
+		"following.json": data.Following,
+		"media.json":     data.Media,
+		"webhooks.json":  data.Webhooks,
+		"drafts.json":    data.Drafts,
+		"blocks.json":    data.Blocks,
+		"mutes.json":     data.Mutes,
+
+		"conversations.json": data.Conversations,
+		"messages.json":      data.Messages,
+		"bookmarks.json":     data.Bookmarks,
+	}
+
+	archive := zip.NewWriter(tmp)
+	for name, content := range files {
+		f, err := archive.Create(name)
+		if err != nil {
+			return "", err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(content)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if cfg.blobs != nil {
+		for _, m := range data.Media {
+			err = exportBlob(archive, cfg.blobs, m.Id)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return "", err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return path, os.Rename(tmp.Name(), path)
+}
+
+func exportBlob(archive *zip.Writer, blobs media.BlobStore, mediaId string) error {
+
+	blob, err := blobs.Open(mediaId)
+	if errors.Is(err, media.ErrBlobNotExists) {
+		log.Printf("Media %s is missing from the blob store, leaving it out of the export", mediaId)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	f, err := archive.Create("media/" + mediaId)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, blob)
+	return err
+}
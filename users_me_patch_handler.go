@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/policy"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// patchUserParams Omitted fields are left untouched
+type patchUserParams struct {
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"current_password"`
+	// RefreshToken The session to keep when the password changes, every other one is signed out
+	RefreshToken string `json:"refresh_token"`
+
+	// Profile fields are public and do not need the current password
+	Handle      *string `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	// PinnedChirpId Zero unpins the current chirp
+	PinnedChirpId *int `json:"pinned_chirp_id"`
+}
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// validateProfile Empty values clear the field, the display name is trimmed in place
+func validateProfile(params *patchUserParams) error {
+
+	if params.Handle != nil {
+		if *params.Handle != "" && !handlePattern.MatchString(*params.Handle) {
+			return errors.New("handle must be 3 to 30 letters, digits or underscores")
+		}
+	}
+
+	if params.DisplayName != nil {
+		displayName := strings.TrimSpace(*params.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength || strings.ContainsFunc(displayName, unicode.IsControl) {
+			return fmt.Errorf("display_name must be at most %d characters without control characters", maxDisplayNameLength)
+		}
+		params.DisplayName = &displayName
+	}
+
+	if params.Bio != nil {
+		if utf8.RuneCountInString(*params.Bio) > maxBioLength {
+			return fmt.Errorf("bio must be at most %d characters", maxBioLength)
+		}
+	}
+
+	if params.AvatarURL != nil {
+		if *params.AvatarURL != "" && !validAvatarURL(*params.AvatarURL) {
+			return errors.New("avatar_url must be an absolute http or https URL")
+		}
+	}
+
+	if params.PinnedChirpId != nil {
+		if *params.PinnedChirpId < 0 {
+			return errors.New("pinned_chirp_id is not valid")
+		}
+	}
+
+	return nil
+}
+
+func validAvatarURL(avatarURL string) bool {
+	if len(avatarURL) > maxAvatarURLLength {
+		return false
+	}
+	u, err := url.Parse(avatarURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (cfg *apiConfig) patchUsersMeHandler(w http.ResponseWriter, r *http.Request) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params := patchUserParams{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUserBodyBytes))
+	err = decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding patch user params: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode user information")
+		return
+	}
+
+	user, err := cfg.DB.UserById(userId)
+	if err != nil {
+		log.Printf("Could not find user to patch: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	emailChanged := params.Email != nil && *params.Email != user.Email
+	if emailChanged || params.Password != nil {
+		if !cfg.confirmCurrentPassword(w, r, user, params.CurrentPassword) {
+			return
+		}
+	}
+
+	if emailChanged && !validEmail(*params.Email) {
+		respondWithError(w, http.StatusBadRequest, "email is not valid")
+		return
+	}
+
+	err = validateProfile(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	patch := database.UserPatch{
+		Handle:           params.Handle,
+		DisplayName:      params.DisplayName,
+		Bio:              params.Bio,
+		AvatarURL:        params.AvatarURL,
+		PinnedChirpId:    params.PinnedChirpId,
+		KeepRefreshToken: params.RefreshToken,
+	}
+	if emailChanged {
+		patch.Email = params.Email
+	}
+
+	if params.Password != nil {
+		email := user.Email
+		if emailChanged {
+			email = *params.Email
+		}
+		err = cfg.passwordPolicy.Check(*params.Password, email)
+		violationErr := policy.ViolationError{}
+		if errors.As(err, &violationErr) {
+			respondWithPolicyError(w, violationErr)
+			return
+		}
+
+		hashed, err := auth.HashPassword(*params.Password)
+		if err != nil {
+			log.Printf("Could not hash new password: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "Error updating user")
+			return
+		}
+		patch.HashedPassword = &hashed
+	}
+
+	user, err = cfg.DB.PatchUser(userId, patch)
+	if err != nil {
+		if errors.Is(err, database.ErrEmailExists) || errors.Is(err, database.ErrHandleExists) || errors.Is(err, database.ErrInvalidPin) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Could not patch user: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating user")
+		return
+	}
+
+	if emailChanged {
+		err = cfg.sendVerificationEmail(user)
+		if err != nil {
+			log.Printf("Could not send verification email to user with id %d: %q", user.Id, err)
+		}
+	}
+
+	log.Printf("Succesfully patched user with id %d", user.Id)
+	respondWithJSON(w, http.StatusOK, newUser(user))
+}
+
+// confirmCurrentPassword Sensitive changes need the current password, wrong guesses count as failed logins.
+func (cfg *apiConfig) confirmCurrentPassword(w http.ResponseWriter, r *http.Request, user database.User, password string) bool {
+
+	if password == "" {
+		respondWithError(w, http.StatusBadRequest, "current_password is required")
+		return false
+	}
+
+	accountKey, ipKey := accountThrottleKey(user.Email), ipThrottleKey(r)
+	if !cfg.checkLoginThrottle(w, accountKey, ipKey) {
+		return false
+	}
+
+	err := auth.ComparePasswordHash(user.HashedPassword, password)
+	if err != nil {
+		log.Printf("Current password of user with id %d does not match", user.Id)
+		cfg.recordLoginFailure(accountKey, ipKey)
+		respondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestPatchUsersMeHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:        db,
+		jwtSecret: "dGVzdA==",
+	}
+
+	users := []string{
+		`{"email": "patched@chirpy.com", "password": "original"}`,
+		`{"email": "taken@chirpy.com", "password": "whatever"}`,
+	}
+	for _, user := range users {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+		cfg.postUsersHandler(w, req)
+	}
+
+	loginW := httptest.NewRecorder()
+	loginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(users[0]))
+	cfg.loginPostHandler(loginW, loginReq)
+
+	loginResp := LoginResponse{}
+	err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+	if err != nil {
+		t.Fatalf("Could not decode login response: %q", err)
+	}
+
+	// A second session that is signed out when the password changes
+	otherLoginW := httptest.NewRecorder()
+	otherLoginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(users[0]))
+	cfg.loginPostHandler(otherLoginW, otherLoginReq)
+
+	otherLoginResp := LoginResponse{}
+	err = json.NewDecoder(otherLoginW.Body).Decode(&otherLoginResp)
+	if err != nil {
+		t.Fatalf("Could not decode login response: %q", err)
+	}
+
+	_, err = db.UpdateSubscription(1, database.SubscriptionChange{
+		Event:     "user.upgraded",
+		Status:    database.SubscriptionActive,
+		PeriodEnd: time.Now().Add(time.Hour),
+		At:        time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Could not upgrade user to chirpy red: %q", err)
+	}
+
+	cases := []struct {
+		request  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			request:  `{}`,
+			wantCode: 200,
+			wantBody: `{"email":"patched@chirpy.com","id":1,"is_chirpy_red":true,"email_verified":false}`,
+		},
+		{
+			request:  `{"email": "new@chirpy.com"}`,
+			wantCode: 400,
+			wantBody: `{"error":"current_password is required"}`,
+		},
+		{
+			request:  `{"email": "new@chirpy.com", "current_password": "wrong"}`,
+			wantCode: 403,
+			wantBody: `{"error":"Current password is incorrect"}`,
+		},
+		{
+			request:  `{"email": "taken@chirpy.com", "current_password": "original"}`,
+			wantCode: 400,
+			wantBody: `{"error":"email already exists"}`,
+		},
+		{
+			request:  `{"email": "TAKEN@chirpy.com", "current_password": "original"}`,
+			wantCode: 400,
+			wantBody: `{"error":"email already exists"}`,
+		},
+		{
+			request:  `{"email": "new@chirpy.com", "current_password": "original"}`,
+			wantCode: 200,
+			wantBody: `{"email":"new@chirpy.com","id":1,"is_chirpy_red":true,"email_verified":false}`,
+		},
+		{
+			request:  fmt.Sprintf(`{"password": "changed", "current_password": "original", "refresh_token": %q}`, loginResp.RefreshToken),
+			wantCode: 200,
+			wantBody: `{"email":"new@chirpy.com","id":1,"is_chirpy_red":true,"email_verified":false}`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Patch Users Me Handler Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PATCH", "/api/users/me", strings.NewReader(c.request))
+			req.Header.Set("Authorization", "Bearer "+loginResp.Token)
+
+			cfg.patchUsersMeHandler(w, req)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	newLoginW := httptest.NewRecorder()
+	newLoginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email": "new@chirpy.com", "password": "changed"}`))
+	cfg.loginPostHandler(newLoginW, newLoginReq)
+	if newLoginW.Code != 200 {
+		t.Errorf("Test failed (login after patch): got %d, want %d", newLoginW.Code, 200)
+	}
+
+	if _, err := db.UserIdFromRefreshToken(loginResp.RefreshToken); err != nil {
+		t.Errorf("Test failed (kept session): got %q, want nil", err)
+	}
+	if _, err := db.UserIdFromRefreshToken(otherLoginResp.RefreshToken); err == nil {
+		t.Errorf("Test failed (other session): got nil, want an error")
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
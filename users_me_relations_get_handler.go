@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (cfg *apiConfig) getUsersMeBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithRelations(w, r, "blocked", cfg.DB.BlockedUsers)
+}
+
+func (cfg *apiConfig) getUsersMeMutesHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.respondWithRelations(w, r, "muted", cfg.DB.MutedUsers)
+}
+
+// respondWithRelations Lists the users the caller blocked or muted, only the caller can see them
+func (cfg *apiConfig) respondWithRelations(w http.ResponseWriter, r *http.Request, kind string, list func(int) ([]database.Relation, error)) {
+
+	authHeader := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	relations, err := list(userId)
+	if err != nil {
+		log.Printf("Could not list %s users of user with id %d: %q", kind, userId, err)
+		respondWithError(w, http.StatusInternalServerError, "Could not retrieve "+kind+" users")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, relations)
+}
@@ -3,16 +3,48 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/policy"
 	"log"
 	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	// maxUserBodyBytes Bodies carrying a password, comfortably above the longest password the policy accepts
+	maxUserBodyBytes = 64 << 10
 )
 
 type User struct {
-	Email       string `json:"email"`
-	ID          int    `json:"id"`
-	IsChirpyRed bool   `json:"is_chirpy_red"`
+	Email         string `json:"email"`
+	ID            int    `json:"id"`
+	IsChirpyRed   bool   `json:"is_chirpy_red"`
+	EmailVerified bool   `json:"email_verified"`
+	Handle        string `json:"handle,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	Bio           string `json:"bio,omitempty"`
+	AvatarURL     string `json:"avatar_url,omitempty"`
+	PinnedChirpId int    `json:"pinned_chirp_id,omitempty"`
+}
+
+// newUser The view of a user meant only for its owner
+func newUser(user database.User) User {
+	return User{
+		Email:         user.Email,
+		ID:            user.Id,
+		IsChirpyRed:   user.Subscription.Active(time.Now()),
+		EmailVerified: user.EmailVerified,
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarURL,
+		PinnedChirpId: user.PinnedChirpId,
+	}
 }
 
 type userParams struct {
@@ -23,19 +55,29 @@ type userParams struct {
 func (cfg *apiConfig) postUsersHandler(w http.ResponseWriter, r *http.Request) {
 
 	params := userParams{}
-	decoder := json.NewDecoder(r.Body)
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUserBodyBytes))
 	err := decoder.Decode(&params)
 
 	if err != nil {
+		maxBytesErr := &http.MaxBytesError{}
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
 		log.Printf("Error decoding user: %q", err)
 		respondWithError(w, http.StatusInternalServerError, "Could not decode user")
 		return
 	}
 
-	validParams, err := validateParams(params)
+	validParams, err := validateParams(params, cfg.passwordPolicy)
 
 	if err != nil {
 		log.Printf("Validation of user parameters failed: %q", err)
+		violationErr := policy.ViolationError{}
+		if errors.As(err, &violationErr) {
+			respondWithPolicyError(w, violationErr)
+			return
+		}
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -51,14 +93,31 @@ func (cfg *apiConfig) postUsersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, User{
-		user.Email,
-		user.Id,
-		user.IsChirpyRed,
-	})
+	// The account is usable even if the email could not be sent, a new link is sent when the email changes
+	err = cfg.sendVerificationEmail(user)
+	if err != nil {
+		log.Printf("Could not send verification email to user with id %d: %q", user.Id, err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, newUser(user))
+}
+
+func (cfg *apiConfig) sendVerificationEmail(user database.User) error {
+
+	token, err := auth.CreateEmailVerificationToken(user.Id, user.Email, cfg.jwtSecret, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/users/verify?token=%s", cfg.baseURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Welcome to Chirpy! Please confirm this is your email address.\n\n"+
+		"Verification link: %s\n\n"+
+		"The link expires in %v.", link, emailVerificationTTL)
+
+	return cfg.sendMail(user.Email, "Verify your Chirpy email", body)
 }
 
-func validateParams(p userParams) (userParams, error) {
+func validateParams(p userParams, passwordPolicy policy.Password) (userParams, error) {
 
 	if p.Email == "" {
 		log.Print("Received an empty email during validation, returning error")
@@ -70,6 +129,16 @@ func validateParams(p userParams) (userParams, error) {
 		return userParams{}, errors.New("password can not be empty")
 	}
 
+	if !validEmail(p.Email) {
+		log.Printf("Received an invalid email %q during validation, returning error", p.Email)
+		return userParams{}, errors.New("email is not valid")
+	}
+
+	err := passwordPolicy.Check(p.Password, p.Email)
+	if err != nil {
+		return userParams{}, err
+	}
+
 	hashed, err := auth.HashPassword(p.Password)
 	if err != nil {
 		log.Printf("Could not generate hash from password: %q", err)
@@ -78,3 +147,13 @@ func validateParams(p userParams) (userParams, error) {
 
 	return userParams{Email: p.Email, Password: hashed}, nil
 }
+
+// validEmail Only bare addresses are accepted, without display names or angle brackets
+func validEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+
+	return address.Address == email && address.Name == ""
+}
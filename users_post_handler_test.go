@@ -1,16 +1,20 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/policy"
 )
 
 func TestPostUsersHandler(t *testing.T) {
@@ -33,12 +37,12 @@ func TestPostUsersHandler(t *testing.T) {
 		{
 			code: 201,
 			body: `{"email": "myemail@chirpy.com", "password": "test1234"}`,
-			want: `{"email":"myemail@chirpy.com","id":1,"is_chirpy_red":false}`,
+			want: `{"email":"myemail@chirpy.com","id":1,"is_chirpy_red":false,"email_verified":false}`,
 		},
 		{
 			code: 201,
 			body: `{"email": "another@email.io", "id": 5958, "password": "1234567890"}`,
-			want: `{"email":"another@email.io","id":2,"is_chirpy_red":false}`,
+			want: `{"email":"another@email.io","id":2,"is_chirpy_red":false,"email_verified":false}`,
 		},
 		{
 			code: 400,
@@ -96,3 +100,101 @@ func TestPostUsersHandler(t *testing.T) {
 	}
 
 }
+
+func TestPostUsersHandlerPasswordPolicy(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	breachedDir := t.TempDir()
+	sum := sha1.Sum([]byte("breached-but-long-enough"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	err = os.WriteFile(filepath.Join(breachedDir, hash[:5]+".txt"), []byte("0000000000000000000000000000000000A:1\r\n"+hash[5:]+":42\r\n"), 0600)
+	if err != nil {
+		t.Fatalf("Could not write breached passwords file: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB: db,
+		passwordPolicy: policy.Password{
+			MinLength:      8,
+			MinEntropyBits: 40,
+			Breached:       policy.BreachedDir{Path: breachedDir},
+		},
+	}
+
+	cases := []struct {
+		code int
+		body string
+		want string
+	}{
+		{
+			code: 400,
+			body: `{"email": "policy@chirpy.com", "password": "abc"}`,
+			want: `{"error":"password does not meet the policy","failed_rules":[` +
+				`{"rule":"min_length","message":"password must be at least 8 characters long"},` +
+				`{"rule":"entropy","message":"password is too easy to guess, try a longer one or a few unrelated words"}]}`,
+		},
+		{
+			code: 400,
+			body: `{"email": "policy@chirpy.com", "password": "Password123!"}`,
+			want: `{"error":"password does not meet the policy","failed_rules":[` +
+				`{"rule":"entropy","message":"password is too easy to guess, try a longer one or a few unrelated words"}]}`,
+		},
+		{
+			code: 400,
+			body: `{"email": "johnsmith@chirpy.com", "password": "xq-johnsmith-zv"}`,
+			want: `{"error":"password does not meet the policy","failed_rules":[` +
+				`{"rule":"user_input","message":"password must not contain your email or other personal information"},` +
+				`{"rule":"entropy","message":"password is too easy to guess, try a longer one or a few unrelated words"}]}`,
+		},
+		{
+			code: 400,
+			body: `{"email": "policy@chirpy.com", "password": "breached-but-long-enough"}`,
+			want: `{"error":"password does not meet the policy","failed_rules":[` +
+				`{"rule":"breached","message":"password appeared in a data breach, choose a different one"}]}`,
+		},
+		{
+			code: 400,
+			body: fmt.Sprintf(`{"email": "policy@chirpy.com", "password": %q}`, strings.Repeat("a", 1025)),
+			want: `{"error":"password does not meet the policy","failed_rules":[` +
+				`{"rule":"max_length","message":"password must be at most 1024 characters long"}]}`,
+		},
+		{
+			code: 413,
+			body: fmt.Sprintf(`{"email": "policy@chirpy.com", "password": %q}`, strings.Repeat("a", maxUserBodyBytes)),
+			want: `{"error":"Request body too large"}`,
+		},
+		{
+			code: 201,
+			body: `{"email": "policy@chirpy.com", "password": "violet staple orbit 42"}`,
+			want: `{"email":"policy@chirpy.com","id":1,"is_chirpy_red":false,"email_verified":false}`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Post Users Handler Password Policy Test Case %d", i), func(t *testing.T) {
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/users", strings.NewReader(c.body))
+
+			cfg.postUsersHandler(w, req)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.want {
+				t.Errorf("Test failed (body): got %q, want %q", got, c.want)
+			}
+			if got := w.Code; got != c.code {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.code)
+			}
+		})
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/benjamin-vq/chirpy/internal/auth"
 	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/policy"
 	"log"
 	"net/http"
 	"strconv"
@@ -35,7 +37,7 @@ func (cfg *apiConfig) putUsersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	params := updateParams{}
-	decoder := json.NewDecoder(r.Body)
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxUserBodyBytes))
 	err = decoder.Decode(&params)
 	if err != nil {
 		log.Printf("Error decoding update params: %q", err)
@@ -47,16 +49,40 @@ func (cfg *apiConfig) putUsersHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Printf("Decoded subject %s is not a valid user id", subject)
 		respondWithError(w, http.StatusInternalServerError, "Invalid user id")
+		return
 	}
 
-	newHashedPassword, err := auth.HashPassword(params.Password)
+	user, err := cfg.DB.UserById(id)
 	if err != nil {
-		log.Printf("Could not hash new password: %q", err)
-		respondWithError(w, http.StatusInternalServerError, "An internal error occurred.")
+		log.Printf("Could not find user to update: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	user.Email = params.Email
+
+	// An omitted password keeps the current one instead of hashing an empty string
+	if params.Password != "" {
+		err = cfg.passwordPolicy.Check(params.Password, params.Email)
+		violationErr := policy.ViolationError{}
+		if errors.As(err, &violationErr) {
+			respondWithPolicyError(w, violationErr)
+			return
+		}
+
+		user.HashedPassword, err = auth.HashPassword(params.Password)
+		if err != nil {
+			log.Printf("Could not hash new password: %q", err)
+			respondWithError(w, http.StatusInternalServerError, "An internal error occurred.")
+			return
+		}
 	}
 
-	err = cfg.DB.UpdateUser(&database.User{Email: params.Email, HashedPassword: newHashedPassword, Id: id})
+	err = cfg.DB.UpdateUser(&user)
 	if err != nil {
+		if errors.Is(err, database.ErrEmailExists) {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		log.Printf("Could not update user: %q", err)
 		respondWithError(w, http.StatusInternalServerError, "Error updating user")
 		return
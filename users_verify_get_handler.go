@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) getUsersVerifyHandler(w http.ResponseWriter, r *http.Request) {
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	userId, email, err := auth.ParseEmailVerificationToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Received an invalid email verification token: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+		return
+	}
+
+	user, err := cfg.DB.VerifyEmail(userId, email)
+	if err != nil {
+		if errors.Is(err, database.UserNotExists) || errors.Is(err, database.ErrEmailChanged) {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		log.Printf("Could not verify email: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not verify email")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newUser(user))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestGetUsersVerifyHandler(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	mailer := &testMailer{}
+	cfg := apiConfig{
+		DB:                   db,
+		jwtSecret:            "dGVzdA==",
+		mailer:               mailer,
+		requireVerifiedEmail: true,
+	}
+
+	invalidW := httptest.NewRecorder()
+	invalidReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"email": "Name <name@chirpy.com>", "password": "pw"}`))
+	cfg.postUsersHandler(invalidW, invalidReq)
+	if invalidW.Code != 400 {
+		t.Errorf("Test failed (invalid email): got %d, want %d", invalidW.Code, 400)
+	}
+
+	user := `{"email": "unverified@chirpy.com", "password": "verify-me"}`
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+	cfg.postUsersHandler(createW, createReq)
+
+	loginW := httptest.NewRecorder()
+	loginReq := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+	cfg.loginPostHandler(loginW, loginReq)
+
+	loginResp := LoginResponse{}
+	err = json.NewDecoder(loginW.Body).Decode(&loginResp)
+	if err != nil {
+		t.Fatalf("Could not decode login response: %q", err)
+	}
+
+	postChirp := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "Am I verified?"}`))
+		req.Header.Add("Authorization", "Bearer "+loginResp.Token)
+		cfg.postChirpHandler(w, req)
+		return w.Code
+	}
+
+	if got := postChirp(); got != 403 {
+		t.Errorf("Test failed (unverified chirp): got %d, want %d", got, 403)
+	}
+
+	link, err := url.Parse(mailer.valueAfter("Verification link:"))
+	if err != nil {
+		t.Fatalf("Could not parse verification link: %q", err)
+	}
+	token := link.Query().Get("token")
+
+	changedToken, _ := auth.CreateEmailVerificationToken(1, "previous@chirpy.com", cfg.jwtSecret, emailVerificationTTL)
+
+	cases := []struct {
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			token:    "",
+			wantCode: 400,
+			wantBody: `{"error":"Missing token"}`,
+		},
+		{
+			token:    loginResp.Token,
+			wantCode: 400,
+			wantBody: `{"error":"Invalid or expired token"}`,
+		},
+		{
+			token:    changedToken,
+			wantCode: 400,
+			wantBody: `{"error":"Invalid or expired token"}`,
+		},
+		{
+			token:    token,
+			wantCode: 200,
+			wantBody: `{"email":"unverified@chirpy.com","id":1,"is_chirpy_red":false,"email_verified":true}`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Users Verify Get Handler Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/users/verify?token="+url.QueryEscape(c.token), nil)
+
+			cfg.getUsersVerifyHandler(w, req)
+
+			resp, _ := io.ReadAll(w.Body)
+			if got := string(resp); got != c.wantBody {
+				t.Errorf("Test failed (body): got %s, want %s", got, c.wantBody)
+			}
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	if got := postChirp(); got != 201 {
+		t.Errorf("Test failed (verified chirp): got %d, want %d", got, 201)
+	}
+
+	// Verification tokens must not work as access tokens
+	if _, err := auth.UserIdFromToken(token, cfg.jwtSecret); err == nil {
+		t.Errorf("Test failed, verification token accepted as access token")
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+
+	ownerId, ok := cfg.webhookOwner(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.DB.DeleteWebhookEndpoint(r.PathValue("endpointId"), ownerId)
+	if err != nil {
+		if errors.Is(err, database.WebhookEndpointNotExists) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Could not delete webhook endpoint: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not delete webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusNoContent, "")
+}
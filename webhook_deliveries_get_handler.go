@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	ownerId, ok := cfg.webhookOwner(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, err := cfg.DB.WebhookEndpointById(r.PathValue("endpointId"), ownerId)
+	if err != nil {
+		if errors.Is(err, database.WebhookEndpointNotExists) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Could not find webhook endpoint: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not list deliveries")
+		return
+	}
+
+	deliveries, err := cfg.DB.WebhookDeliveries(endpoint.Id)
+	if err != nil {
+		log.Printf("Could not list webhook deliveries: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not list deliveries")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/assert"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/safehttp"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	eventChirpCreated   = "chirp.created"
+	eventChirpUpdated   = "chirp.updated"
+	eventChirpDeleted   = "chirp.deleted"
+	eventUserUpgraded   = "user.upgraded"
+	eventUserDowngraded = "user.downgraded"
+
+	// webhookDeliveryRetention How long finished deliveries stay in the delivery log
+	webhookDeliveryRetention = 7 * 24 * time.Hour
+	webhookDeliveryTimeout   = 10 * time.Second
+)
+
+var outboundEvents = []string{eventChirpCreated, eventChirpUpdated, eventChirpDeleted, eventUserUpgraded, eventUserDowngraded}
+
+// newWebhookClient Deliveries go to user supplied urls, so internal addresses are refused and redirects are not
+// followed, a redirect counts as a failed delivery.
+func newWebhookClient() *http.Client {
+	client := safehttp.NewClient(webhookDeliveryTimeout)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+// OutboundEvent The body of every delivery, Id is shared by the deliveries of the same event to different endpoints
+type OutboundEvent struct {
+	Id        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type SubscriptionEventData struct {
+	UserId           int                         `json:"user_id"`
+	Status           database.SubscriptionStatus `json:"status"`
+	CurrentPeriodEnd time.Time                   `json:"current_period_end"`
+}
+
+func newDeliveryId() (string, error) {
+	id, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return id[:32], nil
+}
+
+// emitEvent Queues the event for every interested endpoint, failing to do so never fails the caller
+func (cfg *apiConfig) emitEvent(event string, userId int, data any) {
+
+	eventId, err := newDeliveryId()
+	if err != nil {
+		log.Printf("Could not create id for %s event: %q", event, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	payload, err := json.Marshal(OutboundEvent{
+		Id:        eventId,
+		Event:     event,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Could not encode %s event: %q", event, err)
+		return
+	}
+
+	enqueued, err := cfg.DB.EnqueueWebhookDeliveries(event, userId, string(payload), now, newDeliveryId)
+	if err != nil {
+		log.Printf("Could not enqueue deliveries for %s event: %q", event, err)
+		return
+	}
+
+	if enqueued > 0 {
+		cfg.wakeDispatcher()
+	}
+}
+
+func (cfg *apiConfig) emitSubscriptionEvent(before, after database.User) {
+
+	if before.IsChirpyRed == after.IsChirpyRed || after.Subscription == nil {
+		return
+	}
+
+	event := eventUserDowngraded
+	if after.IsChirpyRed {
+		event = eventUserUpgraded
+	}
+	cfg.emitEvent(event, after.Id, SubscriptionEventData{
+		UserId:           after.Id,
+		Status:           after.Subscription.Status,
+		CurrentPeriodEnd: after.Subscription.CurrentPeriodEnd,
+	})
+}
+
+func (cfg *apiConfig) wakeDispatcher() {
+	if cfg.dispatchWake == nil {
+		return
+	}
+	select {
+	case cfg.dispatchWake <- struct{}{}:
+	default:
+	}
+}
+
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting webhook dispatcher, checking for due deliveries every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cfg.dispatchWebhooks(ctx, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping webhook dispatcher: %q", ctx.Err())
+			return
+		case <-cfg.dispatchWake:
+			cfg.dispatchWebhooks(ctx, time.Now())
+		case now := <-ticker.C:
+			cfg.dispatchWebhooks(ctx, now)
+		}
+	}
+}
+
+// dispatchWebhooks Attempts every due delivery once and returns how many succeeded
+func (cfg *apiConfig) dispatchWebhooks(ctx context.Context, now time.Time) int {
+
+	deliveries, endpoints, err := cfg.DB.DueWebhookDeliveries(now)
+	if err != nil {
+		log.Printf("Webhook dispatcher could not load due deliveries: %q", err)
+		return 0
+	}
+
+	succeeded := 0
+	for _, delivery := range deliveries {
+		endpoint := endpoints[delivery.EndpointId]
+		attempt := cfg.deliver(ctx, endpoint, delivery, now)
+		ok := attempt.Error == ""
+		if ok {
+			succeeded++
+		}
+
+		tries := len(delivery.Attempts) + 1
+		giveUp := !ok && tries >= cfg.outboundRetry.maxAttempts
+		retryAt := now.Add(backoff(tries-1, cfg.outboundRetry.baseDelay, cfg.outboundRetry.maxDelay))
+		if !ok {
+			log.Printf("Delivery %s to endpoint %s failed (attempt %d): %s", delivery.Id, endpoint.Id, tries, attempt.Error)
+		}
+
+		disabled, err := cfg.DB.RecordDeliveryAttempt(delivery.Id, attempt, ok, giveUp, retryAt,
+			now.Add(webhookDeliveryRetention), cfg.endpointDisableAfter)
+		if err != nil {
+			log.Printf("Could not record attempt of delivery %s: %q", delivery.Id, err)
+			continue
+		}
+		if disabled {
+			log.Printf("Disabled webhook endpoint %s after %d consecutive failures", endpoint.Id, cfg.endpointDisableAfter)
+			cfg.disabledEndpoints.Add(1)
+		}
+	}
+
+	return succeeded
+}
+
+// deliver Any 2xx response is a success, everything else is retried
+func (cfg *apiConfig) deliver(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery, now time.Time) database.DeliveryAttempt {
+
+	attempt := database.DeliveryAttempt{At: now}
+	timestamp := now.Unix()
+
+	ctx, cancel := context.WithTimeout(ctx, webhookDeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("Chirpy-Event", delivery.Event)
+	req.Header.Set("Chirpy-Delivery", delivery.Id)
+	req.Header.Set("Chirpy-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Chirpy-Signature", auth.SignWebhook(endpoint.Secret, timestamp, []byte(delivery.Payload)))
+
+	// A default client would reach internal addresses, tests have to pick their client explicitly
+	assert.That(cfg.webhookClient != nil, "Webhook client should be configured")
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	// Draining a bit of the body lets the connection be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	return attempt
+}
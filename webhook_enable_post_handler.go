@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) postWebhookEnableHandler(w http.ResponseWriter, r *http.Request) {
+
+	ownerId, ok := cfg.webhookOwner(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, err := cfg.DB.EnableWebhookEndpoint(r.PathValue("endpointId"), ownerId)
+	if err != nil {
+		if errors.Is(err, database.WebhookEndpointNotExists) {
+			respondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		log.Printf("Could not enable webhook endpoint: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not enable webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookEndpointResponse(endpoint))
+}
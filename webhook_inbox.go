@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookEventRetention Processed events are kept this long to reject replays, long enough to outlive the retries of the sender
+const webhookEventRetention = 72 * time.Hour
+
+// webhookRetry With zero attempts failing events go straight to the dead letters
+type webhookRetry struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+// wakeWebhookWorker Never blocks, a wake up already pending covers this one too
+func (cfg *apiConfig) wakeWebhookWorker() {
+	if cfg.webhookWake == nil {
+		return
+	}
+	select {
+	case cfg.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// runWebhookWorker Polls for retries that became due, new events wake it up right away
+func (cfg *apiConfig) runWebhookWorker(ctx context.Context, interval time.Duration) {
+	log.Printf("Starting webhook worker, checking for due events every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Events stored before a restart are still pending
+	cfg.processWebhookInbox(time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping webhook worker: %q", ctx.Err())
+			return
+		case <-cfg.webhookWake:
+			cfg.processWebhookInbox(time.Now())
+		case now := <-ticker.C:
+			cfg.processWebhookInbox(now)
+		}
+	}
+}
+
+// processWebhookInbox Handles every due event and returns how many were processed successfully
+func (cfg *apiConfig) processWebhookInbox(now time.Time) int {
+
+	events, err := cfg.DB.DueWebhookEvents(now)
+	if err != nil {
+		log.Printf("Webhook worker could not load due events: %q", err)
+		return 0
+	}
+
+	processed := 0
+	for _, event := range events {
+		err := cfg.processInboxEvent(event)
+		if err == nil {
+			processed++
+			err = cfg.DB.MarkWebhookEventProcessed(event.Id, now.Add(webhookEventRetention))
+			if err != nil {
+				log.Printf("Could not mark webhook event %s as processed: %q", event.Id, err)
+			}
+			continue
+		}
+
+		attempt := event.Attempts + 1
+		dead := attempt >= cfg.webhookRetry.maxAttempts
+		retryAt := now.Add(backoff(attempt-1, cfg.webhookRetry.baseDelay, cfg.webhookRetry.maxDelay))
+		if dead {
+			log.Printf("Webhook event %s failed %d times, moving it to the dead letters: %q", event.Id, attempt, err)
+			cfg.deadWebhookEvents.Add(1)
+		} else {
+			log.Printf("Webhook event %s failed, retrying at %v: %q", event.Id, retryAt, err)
+		}
+
+		err = cfg.DB.MarkWebhookEventFailed(event.Id, err.Error(), retryAt, dead)
+		if err != nil {
+			log.Printf("Could not mark webhook event %s as failed: %q", event.Id, err)
+		}
+	}
+
+	return processed
+}
+
+func (cfg *apiConfig) processInboxEvent(event database.InboxEvent) error {
+
+	switch event.Source {
+	case polkaEventSource:
+		params := PolkaParams{}
+		err := json.Unmarshal([]byte(event.Payload), &params)
+		if err != nil {
+			return err
+		}
+
+		code, message := cfg.handlePolkaEvent(params)
+		if code != http.StatusNoContent {
+			return fmt.Errorf("%s event for user %d failed with status %d %s", params.Event, params.Data.UserId, code, message)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown webhook source %q", event.Source)
+	}
+}
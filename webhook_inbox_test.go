@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+func TestWebhookInbox(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:                      db,
+		polkaApiKey:             "1234",
+		polkaWebhookSecret:      "whsec_test",
+		polkaSignatureTolerance: 5 * time.Minute,
+		adminApiKey:             "admin",
+		webhookRetry: webhookRetry{
+			maxAttempts: 2,
+			baseDelay:   time.Minute,
+			maxDelay:    time.Hour,
+		},
+	}
+
+	// The user does not exist yet, so processing fails until it does
+	w := httptest.NewRecorder()
+	event := `{"id": "evt_1", "event": "user.upgraded", "data": {"user_id": 1}}`
+	req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(event))
+	req.Header.Set("Authorization", "ApiKey 1234")
+	signPolkaRequest(req, cfg.polkaWebhookSecret, event)
+	cfg.postPolkaHandler(w, req)
+	if w.Code != 204 {
+		t.Fatalf("Test failed (acknowledge code): got %d, want %d", w.Code, 204)
+	}
+
+	now := time.Now()
+	listEvents := func(status string) []database.InboxEvent {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/admin/webhooks?status="+status, nil)
+		req.Header.Set("Authorization", "ApiKey admin")
+		cfg.adminWebhooksGetHandler(w, req)
+
+		events := []database.InboxEvent{}
+		if err := json.NewDecoder(w.Body).Decode(&events); err != nil {
+			t.Fatalf("Could not decode webhook events: %q", err)
+		}
+		return events
+	}
+
+	steps := []struct {
+		at            time.Time
+		wantProcessed int
+		wantStatus    database.InboxStatus
+		wantAttempts  int
+	}{
+		{at: now, wantProcessed: 0, wantStatus: database.InboxPending, wantAttempts: 1},
+		// Not due before the backoff delay
+		{at: now.Add(30 * time.Second), wantProcessed: 0, wantStatus: database.InboxPending, wantAttempts: 1},
+		{at: now.Add(time.Minute), wantProcessed: 0, wantStatus: database.InboxDead, wantAttempts: 2},
+		// Dead events are not retried anymore
+		{at: now.Add(24 * time.Hour), wantProcessed: 0, wantStatus: database.InboxDead, wantAttempts: 2},
+	}
+
+	for i, s := range steps {
+		t.Run(fmt.Sprintf("Webhook Inbox Retry Test Case %d", i), func(t *testing.T) {
+			if got := cfg.processWebhookInbox(s.at); got != s.wantProcessed {
+				t.Errorf("Test failed (processed): got %d, want %d", got, s.wantProcessed)
+			}
+
+			events := listEvents("")
+			if len(events) != 1 {
+				t.Fatalf("Test failed (events): got %d, want %d", len(events), 1)
+			}
+			if events[0].Status != s.wantStatus || events[0].Attempts != s.wantAttempts {
+				t.Errorf("Test failed (event): got %s after %d attempts, want %s after %d",
+					events[0].Status, events[0].Attempts, s.wantStatus, s.wantAttempts)
+			}
+		})
+	}
+
+	dead := listEvents("dead")
+	if len(dead) != 1 {
+		t.Fatalf("Test failed (dead letters): got %d, want %d", len(dead), 1)
+	}
+
+	createW := httptest.NewRecorder()
+	createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(`{"email": "late@chirpy.com", "password": "hey!"}`))
+	cfg.postUsersHandler(createW, createReq)
+
+	replayCases := []struct {
+		apiKey   string
+		eventId  string
+		wantCode int
+	}{
+		{apiKey: "wrong", eventId: dead[0].Id, wantCode: 401},
+		{apiKey: "admin", eventId: "missing", wantCode: 404},
+		{apiKey: "admin", eventId: dead[0].Id, wantCode: 202},
+		{apiKey: "admin", eventId: dead[0].Id, wantCode: 409},
+	}
+
+	for i, c := range replayCases {
+		t.Run(fmt.Sprintf("Webhook Replay Test Case %d", i), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/admin/webhooks/"+c.eventId+"/replay", nil)
+			req.SetPathValue("eventId", c.eventId)
+			req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+			cfg.adminWebhookReplayPostHandler(w, req)
+
+			if w.Code != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+
+	t.Run("Webhook Replay Processing Test", func(t *testing.T) {
+		if got := cfg.processWebhookInbox(time.Now()); got != 1 {
+			t.Errorf("Test failed (processed): got %d, want %d", got, 1)
+		}
+
+		user, _ := db.UserById(1)
+		if !user.IsChirpyRed {
+			t.Errorf("Test failed (chirpy red): got %t, want %t", user.IsChirpyRed, true)
+		}
+
+		if got := listEvents("processed"); len(got) != 1 {
+			t.Errorf("Test failed (processed events): got %d, want %d", len(got), 1)
+		}
+	})
+
+	t.Run("Admin Disabled Test", func(t *testing.T) {
+		disabled := apiConfig{DB: db}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/admin/webhooks", nil)
+		req.Header.Set("Authorization", "ApiKey ")
+		disabled.adminWebhooksGetHandler(w, req)
+
+		if w.Code != 404 {
+			t.Errorf("Test failed (code): got %d, want %d", w.Code, 404)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
+
+func TestWebhookInboxConcurrentWrites(t *testing.T) {
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	now := time.Now()
+	_, err = db.EnqueueWebhookEvent(database.InboxEvent{Id: "first", Source: "polka", EventId: "evt_first", ReceivedAt: now})
+	if err != nil {
+		t.Fatalf("Could not enqueue webhook event: %q", err)
+	}
+
+	// Writers that load and write separately lose each other's changes
+	const writers = 50
+	wg := sync.WaitGroup{}
+	for i := range writers {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("evt_%d", i)
+			if _, err := db.EnqueueWebhookEvent(database.InboxEvent{Id: id, Source: "polka", EventId: id, ReceivedAt: now}); err != nil {
+				t.Errorf("Could not enqueue webhook event: %q", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := db.MarkWebhookEventProcessed("first", now.Add(time.Hour)); err != nil {
+				t.Errorf("Could not mark webhook event processed: %q", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	pending, _ := db.WebhookEvents(database.InboxPending)
+	processed, _ := db.WebhookEvents(database.InboxProcessed)
+	if len(pending) != writers || len(processed) != 1 || processed[0].Attempts != writers {
+		t.Errorf("Test failed (events): got %d pending and %d processed, want %d and %d", len(pending), len(processed), writers, 1)
+	}
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}
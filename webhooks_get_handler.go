@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+func (cfg *apiConfig) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+
+	ownerId, ok := cfg.webhookOwner(w, r)
+	if !ok {
+		return
+	}
+
+	endpoints, err := cfg.DB.WebhookEndpointsByOwner(ownerId)
+	if err != nil {
+		log.Printf("Could not list webhook endpoints: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not list webhooks")
+		return
+	}
+
+	response := make([]WebhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, newWebhookEndpointResponse(endpoint))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
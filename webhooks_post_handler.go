@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+	"github.com/benjamin-vq/chirpy/internal/safehttp"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxWebhookURLLength = 2048
+
+// WebhookEndpointResponse The secret is only shown when the endpoint is created
+type WebhookEndpointResponse struct {
+	Id                  string     `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	Secret              string     `json:"secret,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+func newWebhookEndpointResponse(endpoint database.WebhookEndpoint) WebhookEndpointResponse {
+	return WebhookEndpointResponse{
+		Id:                  endpoint.Id,
+		URL:                 endpoint.URL,
+		Events:              endpoint.Events,
+		Enabled:             endpoint.Enabled,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+		DisabledAt:          endpoint.DisabledAt,
+		CreatedAt:           endpoint.CreatedAt,
+	}
+}
+
+// webhookOwner Users manage their own endpoints with their access token, admins use their api key and manage the
+// endpoints receiving every event, which belong to owner 0.
+func (cfg *apiConfig) webhookOwner(w http.ResponseWriter, r *http.Request) (int, bool) {
+
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "ApiKey ") {
+		return 0, cfg.authorizeAdmin(w, r)
+	}
+
+	token, found := strings.CutPrefix(authHeader, "Bearer ")
+	if !found {
+		log.Printf("Invalid authorization header: %q", authHeader)
+		respondWithError(w, http.StatusUnauthorized, "Missing authorization")
+		return 0, false
+	}
+
+	userId, err := auth.UserIdFromToken(token, cfg.jwtSecret)
+	if err != nil {
+		log.Printf("Error retrieving user id from token: %q", err)
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return 0, false
+	}
+
+	return userId, true
+}
+
+func (cfg *apiConfig) postWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+
+	ownerId, ok := cfg.webhookOwner(w, r)
+	if !ok {
+		return
+	}
+
+	type webhookParams struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	params := webhookParams{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("Error decoding webhook params: %q", err)
+		respondWithError(w, http.StatusBadRequest, "Could not decode webhook")
+		return
+	}
+
+	if !validWebhookURL(params.URL) {
+		respondWithError(w, http.StatusBadRequest, "url must be an absolute http or https URL")
+		return
+	}
+	if !cfg.allowInternalWebhooks && internalWebhookURL(params.URL) {
+		respondWithError(w, http.StatusBadRequest, "url can not point at an internal address")
+		return
+	}
+
+	events := make([]string, 0, len(params.Events))
+	for _, event := range params.Events {
+		if !slices.Contains(outboundEvents, event) {
+			respondWithError(w, http.StatusBadRequest, "unknown event "+event+", should be one of "+strings.Join(outboundEvents, ", "))
+			return
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "events can not be empty")
+		return
+	}
+
+	endpointId, err := newDeliveryId()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create webhook")
+		return
+	}
+	secret, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not create webhook")
+		return
+	}
+
+	endpoint, err := cfg.DB.CreateWebhookEndpoint(database.WebhookEndpoint{
+		Id:        endpointId,
+		OwnerId:   ownerId,
+		URL:       params.URL,
+		Secret:    "whsec_" + secret,
+		Events:    events,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		if errors.Is(err, database.UserNotExists) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		log.Printf("Could not create webhook endpoint: %q", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not create webhook")
+		return
+	}
+
+	response := newWebhookEndpointResponse(endpoint)
+	response.Secret = endpoint.Secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func validWebhookURL(webhookURL string) bool {
+	if len(webhookURL) > maxWebhookURLLength {
+		return false
+	}
+	u, err := url.Parse(webhookURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func internalWebhookURL(webhookURL string) bool {
+	u, err := url.Parse(webhookURL)
+	return err != nil || safehttp.BlockedHost(u.Hostname())
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benjamin-vq/chirpy/internal/auth"
+	"github.com/benjamin-vq/chirpy/internal/database"
+)
+
+type receivedWebhook struct {
+	event string
+	body  []byte
+	valid bool
+}
+
+// testReceiver Records every delivery and answers with the status codes in order, repeating the last one
+type testReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	codes    []int
+	received []receivedWebhook
+}
+
+func (tr *testReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	err := auth.VerifyWebhookSignature(tr.secret, r.Header.Get("Chirpy-Signature"), r.Header.Get("Chirpy-Timestamp"),
+		body, time.Now(), time.Minute)
+	tr.received = append(tr.received, receivedWebhook{event: r.Header.Get("Chirpy-Event"), body: body, valid: err == nil})
+
+	code := tr.codes[min(len(tr.received), len(tr.codes))-1]
+	w.WriteHeader(code)
+}
+
+func TestOutboundWebhooks(t *testing.T) {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	db, err := database.NewDB(testDbName)
+	if err != nil {
+		log.Fatalf("Could not create new database for test: %q", err)
+	}
+
+	cfg := apiConfig{
+		DB:          db,
+		jwtSecret:   "dGVzdA==",
+		adminApiKey: "admin",
+		outboundRetry: webhookRetry{
+			maxAttempts: 3,
+			baseDelay:   time.Minute,
+			maxDelay:    time.Hour,
+		},
+		endpointDisableAfter: 2,
+	}
+
+	login := func(user string) LoginResponse {
+		createW := httptest.NewRecorder()
+		createReq := httptest.NewRequest("POST", "/api/users", strings.NewReader(user))
+		cfg.postUsersHandler(createW, createReq)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/login", strings.NewReader(user))
+		cfg.loginPostHandler(w, req)
+
+		loginResp := LoginResponse{}
+		if err := json.NewDecoder(w.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Could not decode login response: %q", err)
+		}
+		return loginResp
+	}
+	partner := login(`{"email": "partner@chirpy.com", "password": "integrate"}`)
+	other := login(`{"email": "other@chirpy.com", "password": "unrelated"}`)
+
+	register := func(authorization, body string) (int, WebhookEndpointResponse) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(body))
+		req.Header.Set("Authorization", authorization)
+		cfg.postWebhooksHandler(w, req)
+
+		endpoint := WebhookEndpointResponse{}
+		json.NewDecoder(w.Body).Decode(&endpoint)
+		return w.Code, endpoint
+	}
+
+	postChirp := func(token string) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(`{"body": "Hello partners"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		cfg.postChirpHandler(w, req)
+	}
+
+	registerCases := []struct {
+		authorization string
+		body          string
+		wantCode      int
+	}{
+		{authorization: "Bearer " + partner.Token, body: `{"url": "ftp://example.com", "events": ["chirp.created"]}`, wantCode: 400},
+		{authorization: "Bearer " + partner.Token, body: `{"url": "https://example.com", "events": ["chirp.liked"]}`, wantCode: 400},
+		{authorization: "Bearer " + partner.Token, body: `{"url": "https://example.com", "events": []}`, wantCode: 400},
+		{authorization: "ApiKey wrong", body: `{"url": "https://example.com", "events": ["chirp.created"]}`, wantCode: 401},
+		{authorization: "Bearer " + partner.Token, body: `{"url": "http://127.0.0.1:8080/hook", "events": ["chirp.created"]}`, wantCode: 400},
+		{authorization: "Bearer " + partner.Token, body: `{"url": "http://LocalHost./hook", "events": ["chirp.created"]}`, wantCode: 400},
+		{authorization: "Bearer " + partner.Token, body: `{"url": "http://[::ffff:10.0.0.1]/hook", "events": ["chirp.created"]}`, wantCode: 400},
+		{authorization: "ApiKey admin", body: `{"url": "http://169.254.169.254/latest/meta-data", "events": ["chirp.created"]}`, wantCode: 400},
+	}
+
+	for i, c := range registerCases {
+		t.Run(fmt.Sprintf("Register Webhook Test Case %d", i), func(t *testing.T) {
+			if got, _ := register(c.authorization, c.body); got != c.wantCode {
+				t.Errorf("Test failed (code): got %d, want %d", got, c.wantCode)
+			}
+		})
+	}
+
+	// The receivers below listen on the loopback address, which the guarded client refuses
+	cfg.allowInternalWebhooks = true
+	cfg.webhookClient = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	t.Run("Signed Delivery Test", func(t *testing.T) {
+		receiver := &testReceiver{codes: []int{200}}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		code, endpoint := register("Bearer "+partner.Token, fmt.Sprintf(`{"url": %q, "events": ["chirp.created"]}`, server.URL))
+		if code != 201 || endpoint.Secret == "" {
+			t.Fatalf("Test failed (register): got %d with secret %q", code, endpoint.Secret)
+		}
+		receiver.secret = endpoint.Secret
+
+		admin := &testReceiver{codes: []int{204}}
+		adminServer := httptest.NewServer(admin)
+		defer adminServer.Close()
+		_, adminEndpoint := register("ApiKey admin", fmt.Sprintf(`{"url": %q, "events": ["chirp.created"]}`, adminServer.URL))
+		admin.secret = adminEndpoint.Secret
+
+		postChirp(partner.Token)
+		// Users only receive events about themselves, admins receive everything
+		postChirp(other.Token)
+
+		if got := cfg.dispatchWebhooks(context.Background(), time.Now()); got != 3 {
+			t.Fatalf("Test failed (delivered): got %d, want %d", got, 3)
+		}
+
+		if len(receiver.received) != 1 || len(admin.received) != 2 {
+			t.Fatalf("Test failed (received): got %d and %d, want %d and %d", len(receiver.received), len(admin.received), 1, 2)
+		}
+		delivery := receiver.received[0]
+		if !delivery.valid || delivery.event != eventChirpCreated {
+			t.Errorf("Test failed (delivery): got event %q with valid signature %t", delivery.event, delivery.valid)
+		}
+		if !strings.Contains(string(delivery.body), `"data":{"body":"Hello partners","id":1,"author_id":1,"visibility":"public"}`) {
+			t.Errorf("Test failed (payload): got %s", delivery.body)
+		}
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/webhooks", nil)
+		req.Header.Set("Authorization", "Bearer "+partner.Token)
+		cfg.getWebhooksHandler(w, req)
+		if body, _ := io.ReadAll(w.Body); strings.Contains(string(body), endpoint.Secret) {
+			t.Errorf("Test failed (secret): listing endpoints leaked the secret")
+		}
+
+		cfg.DB.DeleteWebhookEndpoint(endpoint.Id, 1)
+		cfg.DB.DeleteWebhookEndpoint(adminEndpoint.Id, 0)
+	})
+
+	t.Run("Retry And Disable Test", func(t *testing.T) {
+		receiver := &testReceiver{codes: []int{500}}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		_, endpoint := register("Bearer "+partner.Token, fmt.Sprintf(`{"url": %q, "events": ["chirp.created"]}`, server.URL))
+		receiver.secret = endpoint.Secret
+
+		postChirp(partner.Token)
+		postChirp(partner.Token)
+
+		now := time.Now()
+		cfg.dispatchWebhooks(context.Background(), now)
+
+		deliveriesW := httptest.NewRecorder()
+		deliveriesReq := httptest.NewRequest("GET", "/api/webhooks/"+endpoint.Id+"/deliveries", nil)
+		deliveriesReq.SetPathValue("endpointId", endpoint.Id)
+		deliveriesReq.Header.Set("Authorization", "Bearer "+partner.Token)
+		cfg.getWebhookDeliveriesHandler(deliveriesW, deliveriesReq)
+
+		deliveries := []database.WebhookDelivery{}
+		json.NewDecoder(deliveriesW.Body).Decode(&deliveries)
+		if len(deliveries) != 2 {
+			t.Fatalf("Test failed (deliveries): got %d, want %d", len(deliveries), 2)
+		}
+		for _, delivery := range deliveries {
+			if delivery.Status != database.DeliveryFailed || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != 500 {
+				t.Errorf("Test failed (delivery log): got %+v", delivery)
+			}
+		}
+
+		// Two failures in a row disabled the endpoint and failed its remaining deliveries
+		endpoints, _ := cfg.DB.WebhookEndpointsByOwner(1)
+		if len(endpoints) != 1 || endpoints[0].Enabled || endpoints[0].DisabledAt == nil {
+			t.Fatalf("Test failed (disabled): got %+v", endpoints)
+		}
+
+		postChirp(partner.Token)
+		if got := cfg.dispatchWebhooks(context.Background(), now.Add(time.Hour)); got != 0 || len(receiver.received) != 2 {
+			t.Errorf("Test failed (disabled delivery): got %d deliveries", len(receiver.received))
+		}
+
+		receiver.mu.Lock()
+		receiver.codes = []int{200}
+		receiver.mu.Unlock()
+		enableW := httptest.NewRecorder()
+		enableReq := httptest.NewRequest("POST", "/api/webhooks/"+endpoint.Id+"/enable", nil)
+		enableReq.SetPathValue("endpointId", endpoint.Id)
+		enableReq.Header.Set("Authorization", "Bearer "+other.Token)
+		cfg.postWebhookEnableHandler(enableW, enableReq)
+		if enableW.Code != 404 {
+			t.Errorf("Test failed (enable other owner): got %d, want %d", enableW.Code, 404)
+		}
+
+		if _, err := cfg.DB.EnableWebhookEndpoint(endpoint.Id, 1); err != nil {
+			t.Fatalf("Could not enable endpoint: %q", err)
+		}
+		postChirp(partner.Token)
+		if got := cfg.dispatchWebhooks(context.Background(), now.Add(2*time.Hour)); got != 1 {
+			t.Errorf("Test failed (delivered after enabling): got %d, want %d", got, 1)
+		}
+	})
+
+	err = os.Remove(testDbName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Could not cleanup database file: %q", err)
+	}
+}